@@ -0,0 +1,259 @@
+package hio
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// WithAsync makes a table write its entries asynchronously.
+//
+// Write hands a copy of each entry to background goroutines: a pool of
+// workers encodes and compresses the entries in parallel, outside the lock
+// of the file, and a writer writes them to file in order, while the caller
+// goes on producing entries. At most depth entries (or clusters of
+// entries, for columnar tables, or baskets) are queued: Write blocks once
+// the queue is full.
+// Errors met in the background are returned by the following calls to
+// Write, by Flush and by Close.
+//
+// Records compressed by rio, the default without WithCompression, are
+// compressed by the writer, while holding the lock of the file: use
+// WithCompression to compress entries on the workers.
+//
+// Values reachable from an entry through pointers, slices, maps and
+// exported fields are copied, so the entry may be modified as soon as Write
+// returns. Entries must not hold cycles.
+func WithAsync(depth int) TableOption {
+	return func(table *Table) {
+		if depth < 1 {
+			depth = 1
+		}
+		table.async = depth
+	}
+}
+
+// pipeline runs the writes of a table in the background.
+// Tasks are encoded by a pool of workers, then written by a single writer
+// goroutine, in the order they were submitted.
+type pipeline struct {
+	work  chan *task     // tasks to encode
+	order chan *task     // tasks to write, in order of submission
+	wg    sync.WaitGroup // pending tasks
+
+	mu  sync.Mutex
+	err error // first error met by a task
+}
+
+// task is a write of a pipeline: encode is run by a worker and returns the
+// function writing the encoded data to file, run by the writer.
+type task struct {
+	encode func() (func() error, error)
+	write  func() error
+	err    error
+	ready  chan struct{} // closed once encoded
+}
+
+func newPipeline(depth int) *pipeline {
+	p := &pipeline{
+		work:  make(chan *task, depth),
+		order: make(chan *task, depth),
+	}
+	nworkers := runtime.GOMAXPROCS(0)
+	if nworkers > depth {
+		nworkers = depth
+	}
+	for i := 0; i < nworkers; i++ {
+		go p.encode()
+	}
+	go p.write()
+	return p
+}
+
+// encode encodes tasks, in any order.
+func (p *pipeline) encode() {
+	for t := range p.work {
+		if p.error() == nil {
+			t.write, t.err = t.encode()
+		}
+		close(t.ready)
+	}
+}
+
+// write writes encoded tasks, in order of submission.
+func (p *pipeline) write() {
+	for t := range p.order {
+		<-t.ready
+		if p.error() == nil {
+			err := t.err
+			if err == nil && t.write != nil {
+				err = t.write()
+			}
+			if err != nil {
+				p.mu.Lock()
+				p.err = err
+				p.mu.Unlock()
+			}
+		}
+		p.wg.Done()
+	}
+}
+
+func (p *pipeline) error() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// submit queues a task for execution, unless a previous one failed.
+func (p *pipeline) submit(encode func() (func() error, error)) error {
+	err := p.error()
+	if err != nil {
+		return err
+	}
+	t := &task{
+		encode: encode,
+		ready:  make(chan struct{}),
+	}
+	p.wg.Add(1)
+	p.order <- t
+	p.work <- t
+	return nil
+}
+
+// wait waits for the completion of the queued tasks.
+func (p *pipeline) wait() error {
+	p.wg.Wait()
+	return p.error()
+}
+
+// close waits for the completion of the queued tasks and stops the
+// background goroutines.
+func (p *pipeline) close() error {
+	err := p.wait()
+	close(p.work)
+	close(p.order)
+	return err
+}
+
+// Flush writes the pending entries of the table to file, waiting for the
+// completion of asynchronous writes.
+// Flush returns the first error met while writing entries.
+func (table *Table) Flush() error {
+	if table.file == nil || table.stream == nil {
+		return nil
+	}
+	return table.flush()
+}
+
+// pipeline returns the pipeline of an asynchronous table, starting it if
+// needed, or nil.
+func (table *Table) pipeline() *pipeline {
+	if table.async <= 0 {
+		return nil
+	}
+	if table.pipe == nil {
+		table.pipe = newPipeline(table.async)
+	}
+	return table.pipe
+}
+
+// stop stops the pipeline of an asynchronous table, if any.
+func (table *Table) stop() error {
+	if table.pipe == nil {
+		return nil
+	}
+	err := table.pipe.close()
+	table.pipe = nil
+	return err
+}
+
+// lock serializes the accesses to the stream of the file the table is
+// written to, shared with the pipelines of other tables.
+func (table *Table) lock() {
+	if table.file != nil {
+		table.file.mu.Lock()
+	}
+}
+
+func (table *Table) unlock() {
+	if table.file != nil {
+		table.file.mu.Unlock()
+	}
+}
+
+// deepCopy returns a pointer to a copy of the value pointed at by ptr.
+func deepCopy(ptr interface{}) interface{} {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ptr
+	}
+	cp := reflect.New(rv.Elem().Type())
+	cp.Elem().Set(copyValue(rv.Elem()))
+	return cp.Interface()
+}
+
+// copyValue returns a copy of v sharing no memory reachable through
+// pointers, slices, maps and exported fields.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type().Elem())
+		cp.Elem().Set(copyValue(v.Elem()))
+		return cp
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(copyValue(v.Elem()))
+		return cp
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return cp
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(copyValue(iter.Key()), copyValue(iter.Value()))
+		}
+		return cp
+
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath != "" {
+				continue
+			}
+			cp.Field(i).Set(copyValue(v.Field(i)))
+		}
+		return cp
+	}
+
+	return v
+}
+
+// EOF
@@ -0,0 +1,208 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTableAsync(t *testing.T) {
+	const fname = "testdata/table-async.hio"
+	const nentries = 100
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		rows, err := NewTable(f, "rows", WithAsync(4))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+
+		cols, err := NewTable(f, "cols", WithColumns(10), WithAsync(2))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+
+		// entries compressed by the workers of the pipelines.
+		zrows, err := NewTable(f, "zrows", WithAsync(16), WithCompression("zlib", 1))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+
+		bkts, err := NewTable(f, "bkts", WithBaskets(7, 0), WithAsync(3), WithCompression("flate", 1))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+
+		// entries are modified in place right after each write.
+		var data tableData
+		var col colData
+		for i := 0; i < nentries; i++ {
+			ref := newTableData(i)
+			data.Ints = append(data.Ints[:0], ref.Ints...)
+			data.Floats = append(data.Floats[:0], ref.Floats...)
+			data.Strings = append(data.Strings[:0], ref.Strings...)
+			for _, table := range []*Table{rows, zrows, bkts} {
+				err = table.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write entry [%d] to table [%s]: %v", i, table.Name(), err)
+				}
+			}
+
+			col = newColData(i)
+			err = cols.Write(&col)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+			col.Ints[0] = -1
+
+			if i == nentries/2 {
+				err = rows.Flush()
+				if err != nil {
+					t.Fatalf("could not flush table: %v", err)
+				}
+			}
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var rows Table
+	err = f.Get("rows", &rows)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer rows.Close()
+
+	var cols Table
+	err = f.Get("cols", &cols)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer cols.Close()
+
+	var zrows Table
+	err = f.Get("zrows", &zrows)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer zrows.Close()
+
+	var bkts Table
+	err = f.Get("bkts", &bkts)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer bkts.Close()
+
+	for _, table := range []*Table{&rows, &cols, &zrows, &bkts} {
+		if table.Entries() != nentries {
+			t.Fatalf("table [%s]: expected [%d] entries. got [%d]", table.Name(), nentries, table.Entries())
+		}
+	}
+
+	for i := 0; i < nentries; i++ {
+		for _, table := range []*Table{&rows, &zrows, &bkts} {
+			var data tableData
+			err = table.Read(&data)
+			if err != nil {
+				t.Fatalf("could not read entry [%d] of table [%s]: %v", i, table.Name(), err)
+			}
+			if !reflect.DeepEqual(data, newTableData(i)) {
+				t.Fatalf("table [%s]: expected (n=%d):\nref=%v\nnew=%v", table.Name(), i, newTableData(i), data)
+			}
+		}
+
+		var col colData
+		err = cols.Read(&col)
+		if err != nil {
+			t.Fatalf("could not read entry [%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(col, newColData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newColData(i), col)
+		}
+	}
+}
+
+func TestTableAsyncError(t *testing.T) {
+	const fname = "testdata/table-async-error.hio"
+	defer os.RemoveAll(fname)
+
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	table, err := NewTable(f, "bad", WithAsync(1))
+	if err != nil {
+		t.Fatalf("could not create table: %v", err)
+	}
+
+	// channels can not be encoded.
+	type badData struct {
+		C chan int
+	}
+	for i := 0; i < 3; i++ {
+		err = table.Write(&badData{})
+		if err != nil {
+			break
+		}
+	}
+
+	err = table.Close()
+	if err == nil {
+		t.Fatalf("expected an error closing a table with failed writes")
+	}
+}
+
+func TestFileAsyncError(t *testing.T) {
+	const fname = "testdata/file-async-error.hio"
+	defer os.RemoveAll(fname)
+
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+
+	table, err := NewTable(f, "bad", WithAsync(1))
+	if err != nil {
+		t.Fatalf("could not create table: %v", err)
+	}
+
+	// channels can not be encoded.
+	type badData struct {
+		C chan int
+	}
+	err = table.Write(&badData{})
+	if err != nil {
+		t.Fatalf("could not queue entry: %v", err)
+	}
+
+	err = f.Close()
+	if err == nil {
+		t.Fatalf("expected an error closing a file with failed writes")
+	}
+
+	// the stream is closed nonetheless.
+	_, err = f.Stat()
+	if err == nil {
+		t.Fatalf("expected file [%s] to be closed", fname)
+	}
+}
+
+// EOF
@@ -0,0 +1,305 @@
+package hio
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-hep/rio"
+)
+
+// WithBaskets makes a row-wise table group its entries in baskets, each
+// stored (and compressed) as a single record.
+//
+// A basket is written once it holds nentries entries or an estimated
+// nbytes bytes of data, whichever comes first. A zero value disables the
+// corresponding limit.
+// Grouping entries saves the cost of a record header and of a compression
+// frame per entry, which dominates for small entries.
+// Entries of a basket table can not be projected with ReadColumns.
+func WithBaskets(nentries, nbytes int64) TableOption {
+	return func(table *Table) {
+		table.hdr.Basket = nentries
+		table.hdr.BasketBytes = nbytes
+	}
+}
+
+// basketIndex describes a basket of entries.
+type basketIndex struct {
+	Entries int64 // number of entries in the basket
+	Offset  int64 // position of the basket record
+}
+
+// baskets holds the buffers of a basket table.
+type baskets struct {
+	// write side
+	wbuf   reflect.Value // pending entries
+	nbytes int64         // estimated size of the pending entries
+
+	// read side
+	basket int           // index of the cached basket, -1 if none
+	first  int64         // index of the first entry of the cached basket
+	rbuf   reflect.Value // decoded entries of the cached basket
+}
+
+func (table *Table) baskets() *baskets {
+	if table.bkts == nil {
+		table.bkts = &baskets{
+			basket: -1,
+		}
+	}
+	return table.bkts
+}
+
+// basketed returns whether the table groups its entries in baskets.
+func (table *Table) basketed() bool {
+	return table.hdr.Cluster <= 0 && (table.hdr.Basket > 0 || table.hdr.BasketBytes > 0)
+}
+
+// bktrecname returns the name of the records holding the baskets of a
+// table.
+func bktrecname(table string) string {
+	return "hio.Basket/" + table
+}
+
+// appendBasket appends the value pointed at by ptr to the pending basket,
+// flushing it to file once it is full.
+func (table *Table) appendBasket(ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("hio: basket tables need a pointer to a value (got %T)", ptr)
+	}
+	rv = rv.Elem()
+
+	b := table.baskets()
+	if !b.wbuf.IsValid() {
+		b.wbuf = reflect.MakeSlice(reflect.SliceOf(rv.Type()), 0, int(table.hdr.Basket))
+	}
+	if rv.Type() != b.wbuf.Type().Elem() {
+		return fmt.Errorf("hio: invalid entry type %T for table [%s]", ptr, table.hdr.Name)
+	}
+
+	b.wbuf = reflect.Append(b.wbuf, copyValue(rv))
+	b.nbytes += sizeOf(rv)
+	table.hdr.Entries++
+
+	var err error
+	if (table.hdr.Basket > 0 && int64(b.wbuf.Len()) >= table.hdr.Basket) ||
+		(table.hdr.BasketBytes > 0 && b.nbytes >= table.hdr.BasketBytes) {
+		err = table.flushBasket()
+		if err != nil {
+			return err
+		}
+	}
+
+	if table.file != nil {
+		err = table.file.written()
+	}
+
+	return err
+}
+
+// flushBasket writes the pending basket of entries to file.
+func (table *Table) flushBasket() error {
+	b := table.baskets()
+	if !b.wbuf.IsValid() || b.wbuf.Len() == 0 || table.stream == nil {
+		return nil
+	}
+
+	pipe := table.pipeline()
+	if pipe == nil {
+		err := table.writeBasket(b.wbuf)
+		if err != nil {
+			return err
+		}
+		b.wbuf = b.wbuf.Slice(0, 0)
+		b.nbytes = 0
+		return nil
+	}
+
+	// hand the buffer over to the pipeline.
+	buf := b.wbuf
+	b.wbuf = reflect.MakeSlice(buf.Type(), 0, int(table.hdr.Basket))
+	b.nbytes = 0
+
+	return pipe.submit(func() (func() error, error) {
+		return table.encodeBasket(buf)
+	})
+}
+
+// writeBasket writes a basket of entries to file and records its position.
+func (table *Table) writeBasket(buf reflect.Value) error {
+	write, err := table.encodeBasket(buf)
+	if err != nil {
+		return err
+	}
+	return write()
+}
+
+// encodeBasket encodes a basket of entries and returns the function
+// writing it to file.
+// Only the returned function accesses the stream of the file.
+func (table *Table) encodeBasket(buf reflect.Value) (func() error, error) {
+	basket := basketIndex{
+		Entries: int64(buf.Len()),
+	}
+
+	ptr := reflect.New(buf.Type())
+	ptr.Elem().Set(buf)
+	blk, err := table.encode(ptr.Interface())
+	if err != nil {
+		return nil, err
+	}
+	rawsize := sizeOf(buf)
+
+	return func() error {
+		table.lock()
+		defer table.unlock()
+
+		rec := table.stream.Record(bktrecname(table.hdr.Name))
+		rec.SetCompress(table.compress())
+
+		err := rec.Connect("hio.Entries", &basket.Entries)
+		if err != nil && err != rio.ErrBlockConnected {
+			return err
+		}
+
+		err = rec.Connect("hio.Basket", blk)
+		if err != nil && err != rio.ErrBlockConnected {
+			return err
+		}
+
+		basket.Offset = table.stream.CurPos()
+		err = table.stream.WriteRecord(rec)
+		if err != nil {
+			return err
+		}
+		table.nbytes += table.stream.CurPos() - basket.Offset
+		table.rawbytes += rawsize
+		table.idx.Baskets = append(table.idx.Baskets, basket)
+
+		return nil
+	}, nil
+}
+
+// readBasket reads entry i of a basket table into ptr.
+func (table *Table) readBasket(i int64, ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("hio: basket tables need a pointer to a value (got %T)", ptr)
+	}
+	rv = rv.Elem()
+
+	b := table.baskets()
+	if b.basket < 0 || i < b.first || i >= b.first+table.idx.Baskets[b.basket].Entries ||
+		b.rbuf.Type().Elem() != rv.Type() {
+		// locate the basket holding entry i and drop the cached one.
+		b.basket = -1
+		first := int64(0)
+		for k, basket := range table.idx.Baskets {
+			if i < first+basket.Entries {
+				b.basket = k
+				break
+			}
+			first += basket.Entries
+		}
+		if b.basket < 0 {
+			return fmt.Errorf("hio: no basket for entry [%d] of table [%s]", i, table.hdr.Name)
+		}
+		b.first = first
+
+		buf, err := table.loadBasket(b.basket, rv.Type())
+		if err != nil {
+			b.basket = -1
+			return err
+		}
+		b.rbuf = buf
+	}
+
+	rv.Set(copyValue(b.rbuf.Index(int(i - b.first))))
+	return nil
+}
+
+// loadBasket reads the entries of the k-th basket.
+func (table *Table) loadBasket(k int, rt reflect.Type) (reflect.Value, error) {
+	recname := bktrecname(table.hdr.Name)
+	rec := table.stream.Record(recname)
+	rec.SetUnpack(true)
+
+	buf := reflect.New(reflect.SliceOf(rt))
+	blk, err := table.wrap(buf.Interface())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	err = rec.Connect("hio.Basket", blk)
+	if err != nil && err != rio.ErrBlockConnected {
+		return reflect.Value{}, err
+	}
+
+	_, err = table.stream.Seek(table.idx.Baskets[k].Offset, 0)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	rec, err = table.stream.ReadRecord()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if rec.Name() != recname {
+		return reflect.Value{}, fmt.Errorf(
+			"hio: invalid record [%s] for basket [%d] of table [%s]",
+			rec.Name(), k, table.hdr.Name,
+		)
+	}
+
+	return buf.Elem(), nil
+}
+
+// sizeOf returns an estimate of the size of v, once encoded.
+func sizeOf(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return 0
+
+	case reflect.String:
+		return 8 + int64(v.Len())
+
+	case reflect.Slice, reflect.Array:
+		n := int64(8)
+		switch v.Type().Elem().Kind() {
+		case reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+			return n + int64(v.Len())*int64(v.Type().Elem().Size())
+		}
+		for i := 0; i < v.Len(); i++ {
+			n += sizeOf(v.Index(i))
+		}
+		return n
+
+	case reflect.Map:
+		n := int64(8)
+		iter := v.MapRange()
+		for iter.Next() {
+			n += sizeOf(iter.Key()) + sizeOf(iter.Value())
+		}
+		return n
+
+	case reflect.Struct:
+		n := int64(0)
+		for i := 0; i < v.NumField(); i++ {
+			n += sizeOf(v.Field(i))
+		}
+		return n
+
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		return sizeOf(v.Elem())
+	}
+
+	return int64(v.Type().Size())
+}
+
+// EOF
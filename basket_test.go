@@ -0,0 +1,220 @@
+package hio
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func testBasketsCreate(t *testing.T, fname string, nentries int, opt TableOption, crash bool) {
+	const tname = "my-table"
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+
+	table, err := NewTable(f, tname, opt)
+	if err != nil {
+		t.Fatalf("could not create table [%s]: %v", tname, err)
+	}
+
+	for i := 0; i < nentries; i++ {
+		data := newTableData(i)
+		err = table.Write(&data)
+		if err != nil {
+			t.Fatalf("could not write to table [i=%d]: %v", i, err)
+		}
+	}
+
+	if crash {
+		err = f.f.Close()
+		if err != nil {
+			t.Fatalf("could not close stream: %v", err)
+		}
+		return
+	}
+
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("could not close file [%s]: %v", fname, err)
+	}
+}
+
+func TestTableBaskets(t *testing.T) {
+	const fname = "testdata/table-baskets.hio"
+	const tname = "my-table"
+	const nentries = 95
+	defer os.RemoveAll(fname)
+
+	for _, test := range []struct {
+		name     string
+		opt      TableOption
+		nbaskets int
+	}{
+		{name: "entries", opt: WithBaskets(10, 0), nbaskets: 10},
+		{name: "bytes", opt: WithBaskets(0, 1024), nbaskets: 12},
+		{name: "both", opt: WithBaskets(5, 1024), nbaskets: 19},
+	} {
+		testBasketsCreate(t, fname, nentries, test.opt, false)
+
+		func() {
+			f, err := Open(fname)
+			if err != nil {
+				t.Fatalf("%s: could not open file [%s]: %v", test.name, fname, err)
+			}
+			defer f.Close()
+
+			var table Table
+			err = f.Get(tname, &table)
+			if err != nil {
+				t.Fatalf("%s: could not retrieve table [%s]: %v", test.name, tname, err)
+			}
+			defer table.Close()
+
+			if table.Entries() != nentries {
+				t.Fatalf("%s: expected [%d] entries. got [%d]", test.name, nentries, table.Entries())
+			}
+			if got := len(table.idx.Baskets); got != test.nbaskets {
+				t.Fatalf("%s: expected [%d] baskets. got [%d]", test.name, test.nbaskets, got)
+			}
+
+			for i := 0; i < nentries; i++ {
+				var data tableData
+				err = table.Read(&data)
+				if err != nil {
+					t.Fatalf("%s: could not read table [i=%d]: %v", test.name, i, err)
+				}
+				if !reflect.DeepEqual(data, newTableData(i)) {
+					t.Fatalf("%s: expected (n=%d):\nref=%v\nnew=%v", test.name, i, newTableData(i), data)
+				}
+			}
+
+			var data tableData
+			err = table.Read(&data)
+			if err != io.EOF {
+				t.Fatalf("%s: expected io.EOF. got %v", test.name, err)
+			}
+
+			for _, i := range rand.Perm(nentries) {
+				var data tableData
+				err = table.ReadAt(int64(i), &data)
+				if err != nil {
+					t.Fatalf("%s: could not read table [i=%d]: %v", test.name, i, err)
+				}
+				if !reflect.DeepEqual(data, newTableData(i)) {
+					t.Fatalf("%s: expected (n=%d):\nref=%v\nnew=%v", test.name, i, newTableData(i), data)
+				}
+			}
+
+			err = table.ReadColumns(&data, "Ints")
+			if err == nil {
+				t.Fatalf("%s: expected an error projecting a basket table", test.name)
+			}
+		}()
+	}
+}
+
+func TestTableBasketsRecover(t *testing.T) {
+	const fname = "testdata/table-baskets-recover.hio"
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+
+	// the last (partial) basket is lost in the crash.
+	testBasketsCreate(t, fname, 25, WithBaskets(10, 0), true)
+
+	err := Recover(fname)
+	if err != nil {
+		t.Fatalf("could not recover file [%s]: %v", fname, err)
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	if table.Entries() != 20 {
+		t.Fatalf("expected [%d] entries. got [%d]", 20, table.Entries())
+	}
+
+	for i := 0; i < 20; i++ {
+		var data tableData
+		err = table.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newTableData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newTableData(i), data)
+		}
+	}
+}
+
+func Benchmark__WriteTableInt64Bkt_(b *testing.B) {
+	const fname = "testdata/bench-write-table-int64-basket.hio"
+	const tname = "my-table"
+	//defer os.RemoveAll(fname)
+
+	b.StopTimer()
+	f, err := Create(fname)
+	if err != nil {
+		b.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	table, err := NewTable(f, tname, WithBaskets(1000, 0))
+	if err != nil {
+		b.Fatalf("could not create table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		data := int64(i)
+		err = table.Write(&data)
+		if err != nil {
+			b.Fatalf("[i=%d] could not write data: %v", i, err)
+		}
+	}
+}
+
+func Benchmark__ReadTableInt64Bkt__(b *testing.B) {
+	const fname = "testdata/bench-write-table-int64-basket.hio"
+	const tname = "my-table"
+	//defer os.RemoveAll(fname)
+
+	b.StopTimer()
+	f, err := Open(fname)
+	if err != nil {
+		b.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		b.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		data := int64(0)
+		err = table.Read(&data)
+		if err != nil && err != io.EOF {
+			b.Fatalf("[i=%d] could not read data: %v (%d)", i, err, table.Entries())
+		}
+	}
+}
+
+// EOF
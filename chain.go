@@ -0,0 +1,205 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// Chain is a sequence of tables with the same name, stored in a list of
+// files, read as a single table.
+//
+// Files are opened lazily, one at a time, as entries are read.
+type Chain struct {
+	name    string
+	fnames  []string
+	entries []int64 // number of entries of each table, -1 if not known yet
+
+	i     int    // index of the file being read
+	f     *File  // file being read, if any
+	table *Table // table being read, if any
+}
+
+// NewChain creates a chain of the tables with the given name stored in the
+// named files.
+func NewChain(name string, fnames ...string) (*Chain, error) {
+	if len(fnames) == 0 {
+		return nil, fmt.Errorf("hio: no file for chain [%s]", name)
+	}
+
+	c := &Chain{
+		name:    name,
+		fnames:  append([]string(nil), fnames...),
+		entries: make([]int64, len(fnames)),
+	}
+	for i := range c.entries {
+		c.entries[i] = -1
+	}
+	return c, nil
+}
+
+// NewChainGlob creates a chain of the tables with the given name stored in
+// the files matching pattern, in lexical order.
+func NewChainGlob(name, pattern string) (*Chain, error) {
+	fnames, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(fnames) == 0 {
+		return nil, fmt.Errorf("hio: no file matching [%s] for chain [%s]", pattern, name)
+	}
+	return NewChain(name, fnames...)
+}
+
+// Name returns the name of the chained tables.
+func (c *Chain) Name() string {
+	return c.name
+}
+
+// Files returns the names of the chained files.
+func (c *Chain) Files() []string {
+	return c.fnames
+}
+
+// Entries returns the total number of entries of the chained tables.
+// The files not opened yet are opened to read the headers of their tables.
+func (c *Chain) Entries() (int64, error) {
+	n := int64(0)
+	for i := range c.fnames {
+		nn, err := c.count(i)
+		if err != nil {
+			return n, err
+		}
+		n += nn
+	}
+	return n, nil
+}
+
+// Read reads the next entry of the chain into ptr.
+// io.EOF is returned after the last entry of the last table.
+func (c *Chain) Read(ptr interface{}) error {
+	for {
+		if c.i >= len(c.fnames) {
+			return io.EOF
+		}
+
+		if c.table == nil {
+			err := c.open(c.i)
+			if err != nil {
+				return err
+			}
+		}
+
+		err := c.table.Read(ptr)
+		if err != io.EOF {
+			return err
+		}
+
+		err = c.close()
+		if err != nil {
+			return err
+		}
+		c.i++
+	}
+}
+
+// ReadAt reads the i-th entry of the chain into ptr.
+// The next call to Read will read the entry following it.
+func (c *Chain) ReadAt(i int64, ptr interface{}) error {
+	if i < 0 {
+		return fmt.Errorf("hio: negative entry index [%d]", i)
+	}
+
+	first := int64(0)
+	for k := range c.fnames {
+		n, err := c.count(k)
+		if err != nil {
+			return err
+		}
+		if i >= first+n {
+			first += n
+			continue
+		}
+
+		if c.table == nil || c.i != k {
+			err = c.close()
+			if err != nil {
+				return err
+			}
+			err = c.open(k)
+			if err != nil {
+				return err
+			}
+		}
+		return c.table.ReadAt(i-first, ptr)
+	}
+
+	return io.EOF
+}
+
+// Close closes the file being read, if any.
+func (c *Chain) Close() error {
+	return c.close()
+}
+
+// count returns the number of entries of the table of the k-th file.
+func (c *Chain) count(k int) (int64, error) {
+	if c.entries[k] >= 0 {
+		return c.entries[k], nil
+	}
+
+	f, err := Open(c.fnames[k])
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(c.name, &table)
+	if err != nil {
+		return 0, fmt.Errorf("hio: could not read table [%s] from [%s]: %v", c.name, c.fnames[k], err)
+	}
+	c.entries[k] = table.Entries()
+
+	return c.entries[k], table.Close()
+}
+
+// open opens the table of the k-th file for reading.
+func (c *Chain) open(k int) error {
+	f, err := Open(c.fnames[k])
+	if err != nil {
+		return err
+	}
+
+	table := &Table{}
+	err = f.Get(c.name, table)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("hio: could not read table [%s] from [%s]: %v", c.name, c.fnames[k], err)
+	}
+
+	c.i = k
+	c.f = f
+	c.table = table
+	c.entries[k] = table.Entries()
+	return nil
+}
+
+// close closes the table and the file being read, if any.
+func (c *Chain) close() error {
+	if c.table == nil {
+		return nil
+	}
+
+	table, f := c.table, c.f
+	c.table, c.f = nil, nil
+
+	err := table.Close()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EOF
@@ -0,0 +1,107 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChain(t *testing.T) {
+	const tname = "my-table"
+	dir, err := os.MkdirTemp("", "hio-chain-")
+	if err != nil {
+		t.Fatalf("could not create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// files holding 5, 0 and 7 entries.
+	sizes := []int{5, 0, 7}
+	nentries := 0
+	for i, n := range sizes {
+		fname := filepath.Join(dir, fmt.Sprintf("chain-%d.hio", i))
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+
+		table, err := NewTable(f, tname)
+		if err != nil {
+			t.Fatalf("could not create table [%s]: %v", tname, err)
+		}
+
+		for j := 0; j < n; j++ {
+			data := int64(nentries)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write to table [i=%d]: %v", nentries, err)
+			}
+			nentries++
+		}
+
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("could not close file [%s]: %v", fname, err)
+		}
+	}
+
+	c, err := NewChainGlob(tname, filepath.Join(dir, "chain-*.hio"))
+	if err != nil {
+		t.Fatalf("could not create chain: %v", err)
+	}
+	defer c.Close()
+
+	if got := len(c.Files()); got != len(sizes) {
+		t.Fatalf("expected [%d] files. got [%d]", len(sizes), got)
+	}
+
+	n, err := c.Entries()
+	if err != nil {
+		t.Fatalf("could not count entries: %v", err)
+	}
+	if n != int64(nentries) {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries, n)
+	}
+
+	for i := 0; i < nentries; i++ {
+		var data int64
+		err = c.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read chain [i=%d]: %v", i, err)
+		}
+		if data != int64(i) {
+			t.Fatalf("expected entry [%d]. got [%d]", i, data)
+		}
+	}
+
+	var data int64
+	err = c.Read(&data)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF. got %v", err)
+	}
+
+	for _, i := range rand.Perm(nentries) {
+		var data int64
+		err = c.ReadAt(int64(i), &data)
+		if err != nil {
+			t.Fatalf("could not read chain [i=%d]: %v", i, err)
+		}
+		if data != int64(i) {
+			t.Fatalf("expected entry [%d]. got [%d]", i, data)
+		}
+	}
+
+	err = c.ReadAt(int64(nentries), &data)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF reading past the end of chain. got %v", err)
+	}
+
+	_, err = NewChainGlob(tname, filepath.Join(dir, "no-such-*.hio"))
+	if err == nil {
+		t.Fatalf("expected an error creating a chain with no file")
+	}
+}
+
+// EOF
@@ -0,0 +1,318 @@
+// hio-dump prints the values stored in hio files in a human-readable form.
+//
+// Values are decoded with the Go type recorded for them in the file, if it
+// is one of the types known to hio-dump: basic types, slices of basic
+// types and hbook 1-dim histograms.
+// Struct values and entries of other types are decoded field by field,
+// with the fields recorded in the file: fields of unknown types are left
+// out of table entries, and prevent struct values from being decoded.
+// Histograms are printed as tables of bins, structs as indented fields and
+// tables as one row per entry.
+//
+// Tables compressed with codecs other than the built-in ones (flate, zlib,
+// gzip and zstd) can not be read: hio-dump refuses files holding them, naming
+// the missing codecs.
+//
+// Usage:
+//
+//	$ hio-dump [options] file.hio [key1 [key2 [...]]]
+//
+// Options:
+//
+//	-n int
+//	      number of table entries to print (-1: all) (default -1)
+//	-skip int
+//	      number of table entries to skip
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-hep/hio"
+)
+
+func main() {
+	n := flag.Int64("n", -1, "number of table entries to print (-1: all)")
+	skip := flag.Int64("skip", 0, "number of table entries to skip")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: hio-dump [options] file.hio [key1 [key2 [...]]]\n\nOptions:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	err := dump(os.Stdout, flag.Arg(0), flag.Args()[1:], *skip, *n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hio-dump: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func dump(w io.Writer, fname string, keys []string, skip, n int64) error {
+	f, err := hio.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = f.CheckCodecs()
+	if err != nil {
+		return err
+	}
+
+	infos := make(map[string]hio.KeyInfo)
+	for _, info := range f.KeyInfos() {
+		infos[info.Name] = info
+	}
+
+	if len(keys) == 0 {
+		keys = f.Keys()
+	}
+
+	for i, key := range keys {
+		info, ok := infos[key]
+		if !ok {
+			return fmt.Errorf("%s: no such key [%s]", fname, key)
+		}
+
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+
+		if info.Table {
+			err = dumpTable(w, f, info, skip, n)
+		} else {
+			err = dumpValue(w, f, info)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: key [%s]: %v", fname, key, err)
+		}
+	}
+
+	return nil
+}
+
+// typeOf returns the Go type of the described value, or of the entries of
+// the described table.
+// For structs of types unknown to hio-dump, typeOf returns a struct type
+// holding the recorded fields of known types, and the other fields.
+func typeOf(info hio.KeyInfo) (reflect.Type, []hio.Field, error) {
+	if rt, ok := types[info.Type]; ok {
+		return rt, nil, nil
+	}
+	if len(info.Fields) == 0 {
+		if info.Type == "" {
+			return nil, nil, fmt.Errorf("no type recorded in file")
+		}
+		return nil, nil, fmt.Errorf("type %s unknown to hio-dump", info.Type)
+	}
+
+	var (
+		fields  []reflect.StructField
+		missing []hio.Field
+	)
+	for _, field := range info.Fields {
+		ft, ok := types[field.Type]
+		if !ok {
+			missing = append(missing, field)
+			continue
+		}
+		fields = append(fields, reflect.StructField{Name: field.Name, Type: ft})
+	}
+	if len(fields) == 0 {
+		return nil, nil, fmt.Errorf("fields of type %s of types unknown to hio-dump", info.Type)
+	}
+	return reflect.StructOf(fields), missing, nil
+}
+
+// describe describes fields, as in "Name (type), ...".
+func describe(fields []hio.Field) string {
+	strs := make([]string, len(fields))
+	for i, field := range fields {
+		strs[i] = fmt.Sprintf("%s (%s)", field.Name, field.Type)
+	}
+	return strings.Join(strs, ", ")
+}
+
+func dumpValue(w io.Writer, f *hio.File, info hio.KeyInfo) error {
+	fmt.Fprintf(w, "%s (%s):\n", info.Name, info.Type)
+
+	rt, missing, err := typeOf(info)
+	if err != nil {
+		fmt.Fprintf(w, "  can not decode: %v\n", err)
+		return nil
+	}
+	if len(missing) > 0 {
+		// values are encoded as a whole.
+		fmt.Fprintf(w, "  can not decode: fields %s of types unknown to hio-dump\n", describe(missing))
+		return nil
+	}
+
+	ptr := reflect.New(rt)
+	err = f.Get(info.Name, ptr.Interface())
+	if err != nil {
+		return err
+	}
+
+	return printValue(w, ptr, "  ")
+}
+
+func dumpTable(w io.Writer, f *hio.File, info hio.KeyInfo, skip, n int64) error {
+	var table hio.Table
+	err := f.Get(info.Name, &table)
+	if err != nil {
+		return err
+	}
+	defer table.Close()
+
+	fmt.Fprintf(w, "%s (table[%s], entries=%d):\n", info.Name, info.Type, table.Entries())
+
+	rt, missing, err := typeOf(info)
+	if err != nil {
+		fmt.Fprintf(w, "  can not decode: %v\n", err)
+		return nil
+	}
+
+	read := table.Read
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "  not decoded: fields %s of types unknown to hio-dump\n", describe(missing))
+		read = func(ptr interface{}) error {
+			return table.ReadColumns(ptr)
+		}
+	}
+
+	if skip > table.Entries() {
+		skip = table.Entries()
+	}
+	_, err = table.Seek(skip, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	for i := skip; n < 0 || i < skip+n; i++ {
+		ptr := reflect.New(rt)
+		err = read(ptr.Interface())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "  [%d] %+v\n", i, ptr.Elem().Interface())
+	}
+
+	return nil
+}
+
+// xyer is implemented by 1-dim histograms.
+type xyer interface {
+	Len() int
+	XY(i int) (x, y float64)
+}
+
+// printValue prints the value v, indenting nested lines with indent.
+func printValue(w io.Writer, v reflect.Value, indent string) error {
+	switch h := v.Interface().(type) {
+	case xyer:
+		fmt.Fprintf(w, "%s%-6s %-14s %s\n", indent, "BIN", "X", "Y")
+		for i := 0; i < h.Len(); i++ {
+			x, y := h.XY(i)
+			fmt.Fprintf(w, "%s%-6d %-14g %g\n", indent, i, x, y)
+		}
+		return nil
+	}
+
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			fmt.Fprintf(w, "%s<nil>\n", indent)
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		rt := v.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			if rt.Field(i).PkgPath != "" {
+				continue
+			}
+			fv := v.Field(i)
+			if scalar(fv) {
+				fmt.Fprintf(w, "%s%s: %v\n", indent, rt.Field(i).Name, fv.Interface())
+				continue
+			}
+			fmt.Fprintf(w, "%s%s:\n", indent, rt.Field(i).Name)
+			err := printValue(w, fv, indent+"  ")
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		vals := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k := fmt.Sprintf("%v", iter.Key().Interface())
+			keys = append(keys, k)
+			vals[k] = iter.Value()
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if scalar(vals[k]) {
+				fmt.Fprintf(w, "%s%s: %v\n", indent, k, vals[k].Interface())
+				continue
+			}
+			fmt.Fprintf(w, "%s%s:\n", indent, k)
+			err := printValue(w, vals[k], indent+"  ")
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		if scalar(v) {
+			fmt.Fprintf(w, "%s%v\n", indent, v.Interface())
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			fmt.Fprintf(w, "%s[%d]:\n", indent, i)
+			err := printValue(w, v.Index(i), indent+"  ")
+			if err != nil {
+				return err
+			}
+		}
+
+	default:
+		fmt.Fprintf(w, "%s%v\n", indent, v.Interface())
+	}
+
+	return nil
+}
+
+// scalar returns whether v fits on a single line.
+func scalar(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Ptr, reflect.Interface:
+		return false
+	case reflect.Slice, reflect.Array:
+		switch v.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Array:
+			return false
+		}
+		return !strings.ContainsRune(fmt.Sprint(v.Interface()), '\n')
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/go-hep/hbook"
+	"github.com/go-hep/hio"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// point and event are unknown to hio-dump: they are decoded field by field.
+type point struct {
+	X, Y float64
+	Tags []string
+}
+
+type event struct {
+	N     int64
+	E     []float64
+	Extra map[string]int // of a type unknown to hio-dump
+}
+
+func TestDump(t *testing.T) {
+	const fname = "testdata/dump.hio"
+	const golden = "testdata/dump.golden"
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := hio.Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		h1 := hbook.NewH1D(4, 0, 4)
+		for i := 0; i < 4; i++ {
+			h1.Fill(float64(i)+0.5, 1)
+		}
+
+		n := int64(42)
+		values := []struct {
+			name string
+			v    hio.Value
+		}{
+			{"n", &n},
+			{"h1", h1},
+			{"point", &point{X: 1, Y: 2, Tags: []string{"a", "b"}}},
+			{"event", &event{N: 1, Extra: map[string]int{"a": 1}}},
+		}
+		for _, v := range values {
+			err = f.Set(v.name, v.v)
+			if err != nil {
+				t.Fatalf("could not set key [%s]: %v", v.name, err)
+			}
+		}
+
+		events, err := hio.NewTable(f, "events")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		points, err := hio.NewTable(f, "points", hio.WithColumns(2), hio.WithCompression("zstd", 0))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			err = events.Write(&event{N: int64(i), E: []float64{float64(i)}, Extra: map[string]int{}})
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+			err = points.Write(&point{X: float64(i), Y: float64(-i)})
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+	}()
+
+	var out bytes.Buffer
+	err := dump(&out, fname, nil, 0, -1)
+	if err != nil {
+		t.Fatalf("could not dump file [%s]: %v", fname, err)
+	}
+
+	if *update {
+		err = os.WriteFile(golden, out.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("hio-dump output differs from %s:\ngot:\n%s\nwant:\n%s", golden, out.Bytes(), want)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+
+	"github.com/go-hep/hbook"
+	"github.com/go-hep/hio"
+)
+
+// types holds the Go types hio-dump can decode, by the name recorded for
+// them in hio files.
+// Values of other types (and tables of entries of other types) are only
+// decoded field by field: add them here to dump them as a whole.
+var types = make(map[string]reflect.Type)
+
+func register(v interface{}) {
+	rt := reflect.TypeOf(v).Elem()
+	types[hio.TypeName(rt)] = rt
+}
+
+func init() {
+	register(new(bool))
+	register(new(int))
+	register(new(int8))
+	register(new(int16))
+	register(new(int32))
+	register(new(int64))
+	register(new(uint))
+	register(new(uint8))
+	register(new(uint16))
+	register(new(uint32))
+	register(new(uint64))
+	register(new(float32))
+	register(new(float64))
+	register(new(string))
+
+	register(new([]bool))
+	register(new([]int))
+	register(new([]int8))
+	register(new([]int16))
+	register(new([]int32))
+	register(new([]int64))
+	register(new([]uint))
+	register(new([]uint8))
+	register(new([]uint16))
+	register(new([]uint32))
+	register(new([]uint64))
+	register(new([]float32))
+	register(new([]float64))
+	register(new([]string))
+
+	register(new(hbook.H1D))
+}
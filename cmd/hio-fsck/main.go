@@ -0,0 +1,72 @@
+// hio-fsck checks hio files and rebuilds the footer of the ones which could
+// not be opened, e.g. because their writer died before closing them.
+//
+// Usage:
+//
+//	$ hio-fsck [options] file1.hio [file2.hio [...]]
+//
+// Options:
+//
+//	-f    rebuild the footer even if the file can be opened
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-hep/hio"
+)
+
+func main() {
+	force := flag.Bool("f", false, "rebuild the footer even if the file can be opened")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: hio-fsck [options] file1.hio [file2.hio [...]]\n\nOptions:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	rc := 0
+	for _, fname := range flag.Args() {
+		err := fsck(fname, *force)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hio-fsck: %s: %v\n", fname, err)
+			rc = 1
+		}
+	}
+	os.Exit(rc)
+}
+
+func fsck(fname string, force bool) error {
+	f, err := hio.Open(fname)
+	if err == nil {
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+		if !force {
+			fmt.Printf("%s: ok\n", fname)
+			return nil
+		}
+	}
+
+	err = hio.Recover(fname)
+	if err != nil {
+		return err
+	}
+
+	f, err = hio.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Printf("%s: recovered %d key(s)\n", fname, len(f.Keys()))
+	return nil
+}
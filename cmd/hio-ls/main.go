@@ -0,0 +1,104 @@
+// hio-ls lists the content of hio files: the version of each file and, for
+// each key, its type, entries (for tables), size on file and position.
+//
+// Tables compressed with codecs other than the built-in ones (flate, zlib,
+// gzip and zstd) can not be read: hio-ls lists files holding them, and fails
+// naming the missing codecs.
+//
+// Usage:
+//
+//	$ hio-ls [options] file1.hio [file2.hio [...]]
+//
+// Options:
+//
+//	-l    also display the estimated uncompressed size and the write time of keys
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/go-hep/hio"
+)
+
+func main() {
+	long := flag.Bool("l", false, "also display the estimated uncompressed size and the write time of keys")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: hio-ls [options] file1.hio [file2.hio [...]]\n\nOptions:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	rc := 0
+	for i, fname := range flag.Args() {
+		if i > 0 {
+			fmt.Println()
+		}
+		err := ls(os.Stdout, fname, *long)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hio-ls: %s: %v\n", fname, err)
+			rc = 1
+		}
+	}
+	os.Exit(rc)
+}
+
+func ls(w io.Writer, fname string, long bool) error {
+	f, err := hio.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fmt.Fprintf(w, "%s: version=%d keys=%d\n", fname, f.Version(), len(f.Keys()))
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	if long {
+		fmt.Fprintf(tw, "NAME\tTYPE\tENTRIES\tSIZE\tRAW\tPOS\tTIME\n")
+	} else {
+		fmt.Fprintf(tw, "NAME\tTYPE\tENTRIES\tSIZE\tPOS\n")
+	}
+
+	for _, key := range f.KeyInfos() {
+		typ := key.Type
+		if typ == "" {
+			typ = "?"
+		}
+		if key.Table {
+			typ = "table[" + typ + "]"
+		}
+
+		entries := "-"
+		if key.Table {
+			entries = fmt.Sprintf("%d", key.Entries)
+		}
+
+		if !long {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\n", key.Name, typ, entries, key.Size, key.Pos)
+			continue
+		}
+
+		stamp := "-"
+		if !key.Time.IsZero() {
+			stamp = key.Time.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%s\n", key.Name, typ, entries, key.Size, key.RawSize, key.Pos, stamp)
+	}
+
+	err = tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	return f.CheckCodecs()
+}
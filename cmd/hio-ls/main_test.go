@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+
+	"github.com/go-hep/hio"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type entry struct {
+	I int64
+	F float64
+}
+
+func TestLs(t *testing.T) {
+	const fname = "testdata/ls.hio"
+	const golden = "testdata/ls.golden"
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := hio.Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		table, err := hio.NewTable(f, "events")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		for i := 0; i < 10; i++ {
+			err = table.Write(&entry{I: int64(i), F: float64(i)})
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+
+		n := int64(42)
+		err = f.Set("n", &n)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+
+		dir, err := f.Mkdir("calib")
+		if err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		scale := 1.5
+		err = dir.Set("scale", &scale)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+	}()
+
+	var out bytes.Buffer
+	err := ls(&out, fname, false)
+	if err != nil {
+		t.Fatalf("could not list file [%s]: %v", fname, err)
+	}
+
+	if *update {
+		err = os.WriteFile(golden, out.Bytes(), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Fatalf("hio-ls output differs from %s:\ngot:\n%s\nwant:\n%s", golden, out.Bytes(), want)
+	}
+}
@@ -0,0 +1,72 @@
+// hio-merge merges hio files, like ROOT's hadd: tables with the same name
+// are concatenated, 1-dim histograms with the same name are added bin by
+// bin and other keys are copied. hio-merge fails on other values with the
+// same name that differ from file to file.
+//
+// Tables compressed with codecs other than the built-in ones (flate, zlib,
+// gzip and zstd) can not be read: hio-merge refuses files holding them, naming
+// the missing codecs.
+//
+// Usage:
+//
+//	$ hio-merge [options] out.hio file1.hio [file2.hio [...]]
+//
+// Options:
+//
+//	-f    overwrite the output file if it exists
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/go-hep/hio"
+)
+
+func main() {
+	force := flag.Bool("f", false, "overwrite the output file if it exists")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: hio-merge [options] out.hio file1.hio [file2.hio [...]]\n\nOptions:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	dst := flag.Arg(0)
+	if _, err := os.Stat(dst); err == nil && !*force {
+		fmt.Fprintf(os.Stderr, "hio-merge: output file [%s] exists (use -f to overwrite it)\n", dst)
+		os.Exit(1)
+	}
+
+	srcs := flag.Args()[1:]
+	for _, src := range srcs {
+		err := checkCodecs(src)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hio-merge: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	err := hio.Merge(dst, srcs...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hio-merge: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// checkCodecs checks the codecs of the tables of the named file are
+// registered.
+func checkCodecs(fname string) error {
+	f, err := hio.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.CheckCodecs()
+}
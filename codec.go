@@ -0,0 +1,305 @@
+package hio
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec compresses and decompresses the entries of a table.
+type Codec interface {
+	// NewWriter returns a writer compressing data to w with the given level.
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+
+	// NewReader returns a reader decompressing data from r.
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = struct {
+	sync.RWMutex
+	m map[string]Codec
+}{
+	m: make(map[string]Codec),
+}
+
+// RegisterCodec makes a codec available under the provided name, for use
+// with WithCompression.
+// Codecs are looked up by name when tables are read, so the same name must
+// be registered by writers and readers.
+// RegisterCodec panics if codec is nil or if a codec is already registered
+// under that name.
+func RegisterCodec(name string, codec Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	if codec == nil {
+		panic("hio: RegisterCodec codec is nil")
+	}
+	if name == "" || name == "none" {
+		panic("hio: RegisterCodec invalid name [" + name + "]")
+	}
+	if _, dup := codecs.m[name]; dup {
+		panic("hio: RegisterCodec called twice for codec [" + name + "]")
+	}
+	codecs.m[name] = codec
+}
+
+// Codecs returns the sorted names of the registered codecs.
+func Codecs() []string {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	names := make([]string, 0, len(codecs.m))
+	for name := range codecs.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckCodecs returns an error naming the codecs compressing tables of the
+// file which are not registered: the entries of these tables can not be
+// read, nor written.
+// Files written by older versions of hio do not record the codec of
+// tables, which is then only checked when entries are read.
+func (f *File) CheckCodecs() error {
+	var missing []string
+	seen := make(map[string]bool)
+	for _, m := range f.metas() {
+		switch m.Codec {
+		case "", "none":
+			continue
+		}
+		if _, err := codecOf(m.Codec); err != nil && !seen[m.Codec] {
+			seen[m.Codec] = true
+			missing = append(missing, m.Codec)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf(
+		"hio: file [%s] has tables compressed with unregistered codecs %q (registered: %q; missing RegisterCodec?)",
+		f.Name(), missing, Codecs(),
+	)
+}
+
+func codecOf(name string) (Codec, error) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	codec, ok := codecs.m[name]
+	if !ok {
+		return nil, fmt.Errorf("hio: unknown codec [%s] (missing RegisterCodec?)", name)
+	}
+	return codec, nil
+}
+
+// WithCompression sets the codec and the compression level used to store
+// the entries of a table.
+//
+// The codec is either "none", to store entries uncompressed, or the name of
+// a registered codec: "flate", "zlib" and "gzip" are always available, with
+// the levels of package compress/flate, as well as "zstd", much faster, with
+// the levels of zstd (1 to 22, or 0 for the default one).
+// The codec is recorded in the table header, so readers decompress entries
+// without further configuration, provided they registered it too: the hio
+// commands only know the built-in codecs, and refuse files holding tables
+// compressed with other ones (see File.CheckCodecs).
+// By default, entries are compressed by rio.
+func WithCompression(codec string, level int) TableOption {
+	return func(table *Table) {
+		table.hdr.Codec = codec
+		table.hdr.Level = int64(level)
+	}
+}
+
+// checkCodec checks the codec and compression level of a new table.
+func (table *Table) checkCodec() error {
+	switch table.hdr.Codec {
+	case "", "none":
+		return nil
+	}
+
+	codec, err := codecOf(table.hdr.Codec)
+	if err != nil {
+		return err
+	}
+
+	w, err := codec.NewWriter(io.Discard, int(table.hdr.Level))
+	if err != nil {
+		return fmt.Errorf("hio: invalid compression level [%d] for codec [%s]: %v", table.hdr.Level, table.hdr.Codec, err)
+	}
+	return w.Close()
+}
+
+// compress returns whether records of the table are compressed by rio.
+func (table *Table) compress() bool {
+	return table.hdr.Codec == ""
+}
+
+// wrap returns the block of data to connect to records of the table for
+// the value pointed at by ptr.
+func (table *Table) wrap(ptr interface{}) (interface{}, error) {
+	switch table.hdr.Codec {
+	case "", "none":
+		return ptr, nil
+	}
+
+	codec, err := codecOf(table.hdr.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("hio: table [%s] needs unregistered codec [%s] (missing RegisterCodec?)", table.hdr.Name, table.hdr.Codec)
+	}
+	return &compressed{
+		codec: codec,
+		level: int(table.hdr.Level),
+		ptr:   ptr,
+	}, nil
+}
+
+// encode returns the block of data to connect to records of the table for
+// the value pointed at by ptr, encoded and compressed if the table has a
+// codec.
+func (table *Table) encode(ptr interface{}) (interface{}, error) {
+	blk, err := table.wrap(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := blk.(*compressed); ok {
+		err = c.encode()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return blk, nil
+}
+
+// compressed is a block of data compressed with a codec.
+// The value is gob-encoded before compression.
+type compressed struct {
+	codec Codec
+	level int
+	ptr   interface{}
+	data  []byte // value already encoded and compressed, if any
+}
+
+// encode encodes and compresses the value ahead of the write of the
+// block, so it can be done outside the lock of the file.
+func (blk *compressed) encode() error {
+	var buf bytes.Buffer
+	err := blk.marshal(&buf)
+	if err != nil {
+		return err
+	}
+	blk.data = buf.Bytes()
+	return nil
+}
+
+func (blk *compressed) MarshalBinary(buf *bytes.Buffer) error {
+	if blk.data != nil {
+		_, err := buf.Write(blk.data)
+		return err
+	}
+	return blk.marshal(buf)
+}
+
+func (blk *compressed) marshal(buf *bytes.Buffer) error {
+	w, err := blk.codec.NewWriter(buf, blk.level)
+	if err != nil {
+		return err
+	}
+
+	err = gob.NewEncoder(w).Encode(blk.ptr)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+func (blk *compressed) UnmarshalBinary(buf *bytes.Buffer) error {
+	r, err := blk.codec.NewReader(buf)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// gob leaves zero-valued fields untouched.
+	rv := reflect.ValueOf(blk.ptr).Elem()
+	rv.Set(reflect.Zero(rv.Type()))
+
+	return gob.NewDecoder(r).Decode(blk.ptr)
+}
+
+type flateCodec struct{}
+
+func (flateCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return flate.NewWriter(w, level)
+}
+
+func (flateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+type zlibCodec struct{}
+
+func (zlibCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return zlib.NewWriterLevel(w, level)
+}
+
+func (zlibCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, level)
+}
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+// zstdCodec compresses with zstd, using a single goroutine per stream:
+// entries are compressed and decompressed concurrently by tables already.
+type zstdCodec struct{}
+
+func (zstdCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	switch {
+	case level < 0 || level > 22:
+		return nil, fmt.Errorf("hio: invalid zstd compression level [%d]", level)
+	case level > 0:
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	return zstd.NewWriter(w, opts...)
+}
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return dec.IOReadCloser(), nil
+}
+
+func init() {
+	RegisterCodec("flate", flateCodec{})
+	RegisterCodec("zlib", zlibCodec{})
+	RegisterCodec("gzip", gzipCodec{})
+	RegisterCodec("zstd", zstdCodec{})
+}
+
+// EOF
@@ -0,0 +1,216 @@
+package hio
+
+import (
+	"compress/flate"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// countingCodec is a flate codec counting the blocks it compresses.
+type countingCodec struct {
+	n *int
+}
+
+func (c countingCodec) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	*c.n++
+	return flate.NewWriter(w, level)
+}
+
+func (c countingCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+func TestTableCompression(t *testing.T) {
+	const fname = "testdata/table-compression.hio"
+	const nentries = 25
+	defer os.RemoveAll(fname)
+
+	var ncompressed int
+	RegisterCodec("test-counting", countingCodec{&ncompressed})
+
+	for _, test := range []struct {
+		codec string
+		level int
+	}{
+		{codec: "", level: 0},
+		{codec: "none", level: 0},
+		{codec: "flate", level: flate.BestSpeed},
+		{codec: "zlib", level: flate.DefaultCompression},
+		{codec: "gzip", level: flate.BestCompression},
+		{codec: "zstd", level: 0},
+		{codec: "zstd", level: 19},
+		{codec: "test-counting", level: flate.HuffmanOnly},
+	} {
+		func() {
+			f, err := Create(fname)
+			if err != nil {
+				t.Fatalf("could not create file [%s]: %v", fname, err)
+			}
+			defer f.Close()
+
+			rows, err := NewTable(f, "rows", WithCompression(test.codec, test.level))
+			if err != nil {
+				t.Fatalf("codec=%q: could not create table: %v", test.codec, err)
+			}
+			cols, err := NewTable(f, "cols", WithColumns(10), WithCompression(test.codec, test.level))
+			if err != nil {
+				t.Fatalf("codec=%q: could not create table: %v", test.codec, err)
+			}
+
+			for i := 0; i < nentries; i++ {
+				data := newTableData(i)
+				err = rows.Write(&data)
+				if err != nil {
+					t.Fatalf("codec=%q: could not write entry [%d]: %v", test.codec, i, err)
+				}
+				col := newColData(i)
+				err = cols.Write(&col)
+				if err != nil {
+					t.Fatalf("codec=%q: could not write entry [%d]: %v", test.codec, i, err)
+				}
+			}
+		}()
+
+		func() {
+			f, err := Open(fname)
+			if err != nil {
+				t.Fatalf("could not open file [%s]: %v", fname, err)
+			}
+			defer f.Close()
+
+			var rows Table
+			err = f.Get("rows", &rows)
+			if err != nil {
+				t.Fatalf("codec=%q: could not retrieve table: %v", test.codec, err)
+			}
+			defer rows.Close()
+
+			var cols Table
+			err = f.Get("cols", &cols)
+			if err != nil {
+				t.Fatalf("codec=%q: could not retrieve table: %v", test.codec, err)
+			}
+			defer cols.Close()
+
+			if rows.hdr.Codec != test.codec || rows.hdr.Level != int64(test.level) {
+				t.Fatalf("codec=%q: invalid header: %+v", test.codec, rows.hdr)
+			}
+
+			for i := 0; i < nentries; i++ {
+				var data tableData
+				err = rows.Read(&data)
+				if err != nil {
+					t.Fatalf("codec=%q: could not read entry [%d]: %v", test.codec, i, err)
+				}
+				if !reflect.DeepEqual(data, newTableData(i)) {
+					t.Fatalf("codec=%q: expected (n=%d):\nref=%v\nnew=%v", test.codec, i, newTableData(i), data)
+				}
+
+				var col colData
+				err = cols.Read(&col)
+				if err != nil {
+					t.Fatalf("codec=%q: could not read entry [%d]: %v", test.codec, i, err)
+				}
+				if !reflect.DeepEqual(col, newColData(i)) {
+					t.Fatalf("codec=%q: expected (n=%d):\nref=%v\nnew=%v", test.codec, i, newColData(i), col)
+				}
+			}
+		}()
+	}
+
+	if ncompressed == 0 {
+		t.Fatalf("registered codec was not used")
+	}
+
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	_, err = NewTable(f, "bad-codec", WithCompression("no-such-codec", 0))
+	if err == nil {
+		t.Fatalf("expected an error creating a table with an unknown codec")
+	}
+
+	_, err = NewTable(f, "bad-level", WithCompression("zlib", 42))
+	if err == nil {
+		t.Fatalf("expected an error creating a table with an invalid level")
+	}
+
+	_, err = NewTable(f, "bad-zstd-level", WithCompression("zstd", 42))
+	if err == nil {
+		t.Fatalf("expected an error creating a table with an invalid zstd level")
+	}
+}
+
+func TestFileCheckCodecs(t *testing.T) {
+	const fname = "testdata/file-check-codecs.hio"
+	const codec = "test-missing"
+	defer os.RemoveAll(fname)
+
+	var n int
+	RegisterCodec(codec, countingCodec{&n})
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		table, err := NewTable(f, "rows", WithCompression(codec, flate.BestSpeed))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		data := newTableData(0)
+		err = table.Write(&data)
+		if err != nil {
+			t.Fatalf("could not write entry: %v", err)
+		}
+	}()
+
+	// the codec is not known to readers.
+	codecs.Lock()
+	delete(codecs.m, codec)
+	codecs.Unlock()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	infos := f.KeyInfos()
+	if len(infos) != 1 || infos[0].Codec != codec {
+		t.Fatalf("expected codec [%s] in key infos. got %+v", codec, infos)
+	}
+
+	err = f.CheckCodecs()
+	if err == nil || !strings.Contains(err.Error(), codec) {
+		t.Fatalf("expected an error naming codec [%s]. got %v", codec, err)
+	}
+
+	var table Table
+	err = f.Get("rows", &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer table.Close()
+
+	var data tableData
+	err = table.Read(&data)
+	if err == nil || !strings.Contains(err.Error(), codec) {
+		t.Fatalf("expected an error naming codec [%s]. got %v", codec, err)
+	}
+}
+
+// EOF
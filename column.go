@@ -0,0 +1,376 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/go-hep/rio"
+)
+
+// WithColumns makes a table store its entries column-wise.
+//
+// Entries must be structs: each exported field is a column, stored in its
+// own records, each holding a cluster of n consecutive entries along with
+// the range of their values for numeric columns.
+// Reading a subset of the columns (see Table.ReadColumns) only
+// decompresses and decodes the records of those columns.
+func WithColumns(n int64) TableOption {
+	return func(table *Table) {
+		table.hdr.Cluster = n
+	}
+}
+
+// clusterIndex describes a cluster of entries of a columnar table.
+type clusterIndex struct {
+	Entries int64      // number of entries in the cluster
+	Offsets []int64    // position of the record of each column
+	Stats   []colStats // range of values of each column
+}
+
+// columns holds the buffers of a columnar table.
+type columns struct {
+	// write side
+	wbufs []reflect.Value // pending values of each column
+	n     int64           // number of pending entries
+
+	// read side
+	cluster int                      // index of the cached cluster, -1 if none
+	first   int64                    // index of the first entry of the cached cluster
+	rbufs   map[string]reflect.Value // decoded columns of the cached cluster
+}
+
+func (table *Table) columns() *columns {
+	if table.cols == nil {
+		table.cols = &columns{
+			cluster: -1,
+			rbufs:   make(map[string]reflect.Value),
+		}
+	}
+	return table.cols
+}
+
+// colrecname returns the name of the records holding a column of a table.
+func colrecname(table, column string) string {
+	return "hio.Column/" + table + "/" + column
+}
+
+// structOf returns the struct value pointed at by ptr.
+func structOf(ptr interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("hio: columnar tables need a pointer to a struct (got %T)", ptr)
+	}
+	return rv.Elem(), nil
+}
+
+// writeColumns appends the fields of the struct pointed at by ptr to the
+// column buffers, flushing them to file once a cluster is complete.
+func (table *Table) writeColumns(ptr interface{}) error {
+	rv, err := structOf(ptr)
+	if err != nil {
+		return err
+	}
+
+	cols := table.columns()
+	if cols.wbufs == nil {
+		if len(table.idx.Columns) == 0 {
+			rt := rv.Type()
+			for i := 0; i < rt.NumField(); i++ {
+				if ft := rt.Field(i); ft.PkgPath == "" {
+					table.idx.Columns = append(table.idx.Columns, ft.Name)
+				}
+			}
+		}
+		cols.wbufs = make([]reflect.Value, len(table.idx.Columns))
+		for i, name := range table.idx.Columns {
+			fv := rv.FieldByName(name)
+			if !fv.IsValid() {
+				return fmt.Errorf("hio: no field [%s] in %T for table [%s]", name, ptr, table.hdr.Name)
+			}
+			cols.wbufs[i] = reflect.MakeSlice(reflect.SliceOf(fv.Type()), 0, int(table.hdr.Cluster))
+		}
+	}
+
+	for i, name := range table.idx.Columns {
+		fv := rv.FieldByName(name)
+		if !fv.IsValid() || fv.Type() != cols.wbufs[i].Type().Elem() {
+			return fmt.Errorf("hio: invalid field [%s] in %T for table [%s]", name, ptr, table.hdr.Name)
+		}
+		cols.wbufs[i] = reflect.Append(cols.wbufs[i], copyValue(fv))
+	}
+	cols.n++
+	table.hdr.Entries++
+
+	if cols.n >= table.hdr.Cluster {
+		err = table.flushColumns()
+		if err != nil {
+			return err
+		}
+	}
+
+	if table.file != nil {
+		err = table.file.written()
+	}
+
+	return err
+}
+
+// flushColumns writes the pending cluster of entries to file, one record
+// per column.
+func (table *Table) flushColumns() error {
+	cols := table.columns()
+	if cols.n == 0 || table.stream == nil {
+		return nil
+	}
+
+	pipe := table.pipeline()
+	if pipe == nil {
+		err := table.writeCluster(cols.wbufs, cols.n)
+		if err != nil {
+			return err
+		}
+		for i := range cols.wbufs {
+			cols.wbufs[i] = cols.wbufs[i].Slice(0, 0)
+		}
+		cols.n = 0
+		return nil
+	}
+
+	// hand the buffers over to the pipeline.
+	bufs, n := cols.wbufs, cols.n
+	cols.wbufs = make([]reflect.Value, len(bufs))
+	for i, buf := range bufs {
+		cols.wbufs[i] = reflect.MakeSlice(buf.Type(), 0, int(table.hdr.Cluster))
+	}
+	cols.n = 0
+
+	return pipe.submit(func() (func() error, error) {
+		return table.encodeCluster(bufs, n)
+	})
+}
+
+// writeCluster writes a cluster of n entries to file, one record per
+// column, and records its position.
+func (table *Table) writeCluster(bufs []reflect.Value, n int64) error {
+	write, err := table.encodeCluster(bufs, n)
+	if err != nil {
+		return err
+	}
+	return write()
+}
+
+// encodeCluster encodes the columns of a cluster of n entries and returns
+// the function writing them to file.
+// Only the returned function accesses the stream of the file.
+func (table *Table) encodeCluster(bufs []reflect.Value, n int64) (func() error, error) {
+	cluster := clusterIndex{
+		Entries: n,
+		Offsets: make([]int64, len(bufs)),
+		Stats:   make([]colStats, len(bufs)),
+	}
+	blks := make([]interface{}, len(bufs))
+	var rawsize int64
+	for i := range bufs {
+		cluster.Stats[i] = statsOf(bufs[i])
+
+		buf := reflect.New(bufs[i].Type())
+		buf.Elem().Set(bufs[i])
+		blk, err := table.encode(buf.Interface())
+		if err != nil {
+			return nil, err
+		}
+		blks[i] = blk
+		rawsize += sizeOf(bufs[i])
+	}
+
+	return func() error {
+		table.lock()
+		defer table.unlock()
+
+		for i, name := range table.idx.Columns {
+			recname := colrecname(table.hdr.Name, name)
+			rec := table.stream.Record(recname)
+			rec.SetCompress(table.compress())
+
+			err := rec.Connect("hio.Entries", &cluster.Entries)
+			if err != nil && err != rio.ErrBlockConnected {
+				return err
+			}
+
+			err = rec.Connect("hio.Stats", &cluster.Stats[i])
+			if err != nil && err != rio.ErrBlockConnected {
+				return err
+			}
+
+			err = rec.Connect("hio.Column", blks[i])
+			if err != nil && err != rio.ErrBlockConnected {
+				return err
+			}
+
+			cluster.Offsets[i] = table.stream.CurPos()
+			err = table.stream.WriteRecord(rec)
+			if err != nil {
+				return err
+			}
+			table.nbytes += table.stream.CurPos() - cluster.Offsets[i]
+		}
+		table.rawbytes += rawsize
+		table.idx.Clusters = append(table.idx.Clusters, cluster)
+
+		return nil
+	}, nil
+}
+
+// ReadColumns reads the next entry of the table into the struct pointed at
+// by ptr, only decoding and filling the named fields.
+// If no name is given, all the fields of the struct which are columns of
+// the table are read, so ptr may point at a struct holding only a subset of
+// the fields of the entries.
+//
+// Projection is supported by columnar tables, and by row-wise tables of
+// struct entries not grouped in baskets.
+// The columns a selection cuts on are read as well.
+func (table *Table) ReadColumns(ptr interface{}, names ...string) error {
+	names = table.selected(names)
+	for {
+		table.skip()
+		err := table.readNext(ptr, names)
+		if err != nil {
+			return err
+		}
+		ok, err := table.match(ptr)
+		if err != nil || ok {
+			return err
+		}
+	}
+}
+
+// readNext reads the named columns of the next entry into ptr.
+func (table *Table) readNext(ptr interface{}, names []string) error {
+	if table.cur >= table.hdr.Entries {
+		return io.EOF
+	}
+
+	var err error
+	switch {
+	case table.hdr.Cluster > 0:
+		err = table.readColumns(table.cur, ptr, names)
+	case table.basketed():
+		if len(names) > 0 {
+			return fmt.Errorf("hio: entries of basket table [%s] can not be projected", table.hdr.Name)
+		}
+		err = table.readBasket(table.cur, ptr)
+	case table.indexed(table.cur):
+		err = table.readRow(table.cur, ptr, names)
+	default:
+		err = fmt.Errorf("hio: table [%s] has no index for entry [%d]", table.hdr.Name, table.cur)
+	}
+	if err != nil {
+		return err
+	}
+	table.cur++
+	return err
+}
+
+// readColumns reads the named columns of entry i into the struct pointed at
+// by ptr.
+func (table *Table) readColumns(i int64, ptr interface{}, names []string) error {
+	if table.hdr.Cluster <= 0 {
+		return fmt.Errorf("hio: table [%s] is not columnar", table.hdr.Name)
+	}
+
+	rv, err := structOf(ptr)
+	if err != nil {
+		return err
+	}
+
+	cols := table.columns()
+	if cols.cluster < 0 || i < cols.first || i >= cols.first+table.idx.Clusters[cols.cluster].Entries {
+		// locate the cluster holding entry i and drop the cached one.
+		cols.cluster = -1
+		first := int64(0)
+		for k, cluster := range table.idx.Clusters {
+			if i < first+cluster.Entries {
+				cols.cluster = k
+				break
+			}
+			first += cluster.Entries
+		}
+		if cols.cluster < 0 {
+			return fmt.Errorf("hio: no cluster for entry [%d] of table [%s]", i, table.hdr.Name)
+		}
+		cols.first = first
+		cols.rbufs = make(map[string]reflect.Value)
+	}
+
+	if len(names) == 0 {
+		// all the columns held by the struct.
+		for _, name := range table.idx.Columns {
+			if rv.FieldByName(name).IsValid() {
+				names = append(names, name)
+			}
+		}
+	}
+
+	for _, name := range names {
+		fv := rv.FieldByName(name)
+		if !fv.IsValid() {
+			return fmt.Errorf("hio: no field [%s] in %T", name, ptr)
+		}
+
+		col, ok := cols.rbufs[name]
+		if !ok || col.Type().Elem() != fv.Type() {
+			col, err = table.loadColumn(cols.cluster, name, fv.Type())
+			if err != nil {
+				return err
+			}
+			cols.rbufs[name] = col
+		}
+		fv.Set(col.Index(int(i - cols.first)))
+	}
+
+	return nil
+}
+
+// loadColumn reads the values of the named column for the k-th cluster.
+func (table *Table) loadColumn(k int, name string, rt reflect.Type) (reflect.Value, error) {
+	j := table.idx.column(name)
+	if j < 0 {
+		return reflect.Value{}, fmt.Errorf("hio: no column [%s] in table [%s]", name, table.hdr.Name)
+	}
+
+	recname := colrecname(table.hdr.Name, name)
+	rec := table.stream.Record(recname)
+	rec.SetUnpack(true)
+
+	buf := reflect.New(reflect.SliceOf(rt))
+	blk, err := table.wrap(buf.Interface())
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	err = rec.Connect("hio.Column", blk)
+	if err != nil && err != rio.ErrBlockConnected {
+		return reflect.Value{}, err
+	}
+
+	_, err = table.stream.Seek(table.idx.Clusters[k].Offsets[j], 0)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	rec, err = table.stream.ReadRecord()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if rec.Name() != recname {
+		return reflect.Value{}, fmt.Errorf(
+			"hio: invalid record [%s] for column [%s] of table [%s]",
+			rec.Name(), name, table.hdr.Name,
+		)
+	}
+
+	return buf.Elem(), nil
+}
+
+// EOF
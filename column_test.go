@@ -0,0 +1,236 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+type colData struct {
+	Px, Py, Pz float64
+	N          int64
+	Name       string
+	Ints       []int64
+}
+
+func newColData(i int) colData {
+	return colData{
+		Px:   float64(i),
+		Py:   float64(i) + 0.5,
+		Pz:   -float64(i),
+		N:    int64(i),
+		Name: fmt.Sprintf("evt-%d", i),
+		Ints: []int64{int64(i), int64(i) + 1},
+	}
+}
+
+func testColumnsCreate(t *testing.T, fname string, nentries int, crash bool) {
+	const tname = "my-table"
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+
+	table, err := NewTable(f, tname, WithColumns(10))
+	if err != nil {
+		t.Fatalf("could not create table [%s]: %v", tname, err)
+	}
+
+	for i := 0; i < nentries; i++ {
+		data := newColData(i)
+		err = table.Write(&data)
+		if err != nil {
+			t.Fatalf("could not write to table [i=%d]: %v", i, err)
+		}
+	}
+
+	if table.Entries() != int64(nentries) {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries, table.Entries())
+	}
+
+	if crash {
+		err = f.f.Close()
+		if err != nil {
+			t.Fatalf("could not close stream: %v", err)
+		}
+		return
+	}
+
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("could not close file [%s]: %v", fname, err)
+	}
+}
+
+func TestTableColumns(t *testing.T) {
+	const fname = "testdata/table-columns.hio"
+	const tname = "my-table"
+	const nentries = 25
+	defer os.RemoveAll(fname)
+	testColumnsCreate(t, fname, nentries, false)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	if table.Entries() != nentries {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries, table.Entries())
+	}
+
+	for i := 0; i < nentries+1; i++ {
+		var data colData
+		err = table.Read(&data)
+		if i == nentries {
+			if err != io.EOF {
+				t.Fatalf("read too many entries (err=%#v)", err)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newColData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newColData(i), data)
+		}
+	}
+
+	err = table.Rewind()
+	if err != nil {
+		t.Fatalf("could not rewind table: %v", err)
+	}
+
+	for i := 0; i < nentries; i++ {
+		var data colData
+		err = table.ReadColumns(&data, "Px", "Name")
+		if err != nil {
+			t.Fatalf("could not read columns [i=%d]: %v", i, err)
+		}
+		ref := colData{Px: float64(i), Name: fmt.Sprintf("evt-%d", i)}
+		if !reflect.DeepEqual(data, ref) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, ref, data)
+		}
+	}
+
+	for _, i := range rand.Perm(nentries) {
+		var data colData
+		err = table.ReadAt(int64(i), &data)
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newColData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newColData(i), data)
+		}
+	}
+
+	var data colData
+	err = table.ReadColumns(&data, "Energy")
+	if err == nil {
+		t.Fatalf("expected an error reading a non-existing column")
+	}
+}
+
+func TestTableColumnsSubset(t *testing.T) {
+	const fname = "testdata/table-columns-subset.hio"
+	const tname = "my-table"
+	const nentries = 25
+	defer os.RemoveAll(fname)
+	testColumnsCreate(t, fname, nentries, false)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	// a struct holding a subset of the columns of the table.
+	type subData struct {
+		Px   float64
+		Name string
+	}
+
+	for i := 0; i < nentries; i++ {
+		var data subData
+		switch i % 2 {
+		case 0:
+			err = table.ReadColumns(&data)
+		case 1:
+			err = table.Read(&data)
+		}
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		ref := subData{Px: float64(i), Name: fmt.Sprintf("evt-%d", i)}
+		if data != ref {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, ref, data)
+		}
+	}
+}
+
+func TestTableColumnsRecover(t *testing.T) {
+	const fname = "testdata/table-columns-recover.hio"
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+
+	// the last (partial) cluster is lost in the crash.
+	testColumnsCreate(t, fname, 25, true)
+
+	err := Recover(fname)
+	if err != nil {
+		t.Fatalf("could not recover file [%s]: %v", fname, err)
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	if table.Entries() != 20 {
+		t.Fatalf("expected [%d] entries. got [%d]", 20, table.Entries())
+	}
+
+	for k, cluster := range table.idx.Clusters {
+		if st := cluster.Stats[table.idx.column("N")]; !st.Valid || st.Max != float64(10*k+9) {
+			t.Fatalf("invalid statistics for cluster [%d]: %+v", k, st)
+		}
+	}
+
+	for i := 0; i < 20; i++ {
+		var data colData
+		err = table.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newColData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newColData(i), data)
+		}
+	}
+}
+
+// EOF
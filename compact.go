@@ -0,0 +1,174 @@
+package hio
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-hep/rio"
+)
+
+// Compact writes to the file dst the keys of the file src, leaving out the
+// records of keys deleted or overwritten since src was created, and stale
+// table headers, indices and footers.
+// Records are copied as they are stored on file, without being decoded.
+func Compact(src, dst string) error {
+	if fi, err := os.Stat(dst); err == nil {
+		si, err := os.Stat(src)
+		if err == nil && os.SameFile(fi, si) {
+			return fmt.Errorf("hio: can not compact file [%s] into itself", src)
+		}
+	}
+
+	r, err := Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := Create(dst)
+	if err != nil {
+		return err
+	}
+
+	err = r.compactTo(w)
+	if err != nil {
+		w.Close()
+		return err
+	}
+
+	return w.Close()
+}
+
+// compactTo copies the keys of f, which must be open for reading, and its
+// directories to w.
+func (f *File) compactTo(w *File) error {
+	for _, key := range f.footer.Keys {
+		err := f.CopyKey(w, key.Name)
+		if err != nil {
+			return err
+		}
+	}
+	w.dirs = append([]string(nil), f.dirs...)
+	return nil
+}
+
+// Compact rewrites the file, which must be open for writing, with only its
+// live keys, reclaiming the space used by deleted or overwritten keys.
+//
+// Pending values and table entries are written first, as with Checkpoint.
+// The compacted file is written next to the file, and replaces it once
+// complete: the file is left untouched if compaction fails.
+// Tables created or retrieved before Compact can not be used afterwards:
+// they have to be retrieved again with Get.
+func (f *File) Compact() error {
+	if f.mode != "w" {
+		return fmt.Errorf("hio: only writable files can be compacted")
+	}
+
+	err := f.Checkpoint()
+	if err != nil {
+		return err
+	}
+
+	fname := f.Name()
+	tmp := fname + ".compact"
+	w, err := f.compact(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	err = os.Rename(tmp, fname)
+	if err != nil {
+		w.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	w.name = fname
+
+	// switch to the compacted file, still open for writing.
+	for _, k := range f.tables.keys() {
+		v, err := f.dict.get(k)
+		if err != nil {
+			return err
+		}
+		err = v.(*Table).stop()
+		if err != nil {
+			return err
+		}
+	}
+
+	if f.raw != nil {
+		err = f.raw.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = f.f.Close()
+	if err != nil {
+		return err
+	}
+
+	f.f = w.f
+	f.raw = w.raw
+	f.header = w.header
+	f.footer = w.footer
+	f.begin = w.begin
+	f.meta = w.meta
+	f.dirs = w.dirs
+	f.ckpt.nrecs = 0
+	f.ckpt.pos = f.f.CurPos()
+
+	// keys are loaded from file on demand, as after OpenFile.
+	f.dict = newdict()
+	f.tosync = newpmap()
+	f.tables = newpmap()
+	for _, key := range f.footer.Keys {
+		f.dict.Set(key.Name, nil)
+	}
+
+	// rebind the header and footer records, connected to those of w.
+	rec := f.f.Record("hio.FileHeader")
+	err = rec.Connect("hio.FileHeader", &f.header)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+
+	rec = f.f.Record("hio.FileFooter")
+	err = rec.Connect("hio.FileFooter", &f.footer)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+
+	return nil
+}
+
+// compact writes the compacted content of the file, checkpointed, to the
+// file tmp, and returns it still open for writing.
+func (f *File) compact(tmp string) (*File, error) {
+	r, err := Open(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	w, err := Create(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.compactTo(w)
+	if err == nil {
+		err = w.Checkpoint()
+	}
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// EOF
@@ -0,0 +1,291 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+// testCompactCreate creates a file holding the values [a] and [b], and the
+// tables [t1] and [t2], from which [b] and [t1] are then deleted and [a]
+// overwritten.
+func testCompactCreate(t *testing.T, fname string, nentries int) {
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		a := int64(1)
+		err = f.Set("a", &a)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+		b := newMyStruct(42)
+		err = f.Set("b", &b)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+
+		for _, name := range []string{"t1", "t2"} {
+			table, err := NewTable(f, name)
+			if err != nil {
+				t.Fatalf("could not create table [%s]: %v", name, err)
+			}
+			for i := 0; i < nentries; i++ {
+				data := newTableData(i)
+				err = table.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write entry [%d]: %v", i, err)
+				}
+			}
+		}
+	}()
+
+	f, err := OpenFile(fname, os.O_RDWR)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer func() {
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("could not close file [%s]: %v", fname, err)
+		}
+	}()
+
+	for _, name := range []string{"b", "t1"} {
+		err = f.Del(name)
+		if err != nil {
+			t.Fatalf("could not delete key [%s]: %v", name, err)
+		}
+	}
+	a := int64(2)
+	err = f.Set("a", &a)
+	if err != nil {
+		t.Fatalf("could not set value: %v", err)
+	}
+}
+
+// testCompactCheck checks the content of a file created by
+// testCompactCreate, once compacted.
+func testCompactCheck(t *testing.T, fname string, nentries int, keys []string) {
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	if got := f.Keys(); !reflect.DeepEqual(got, keys) {
+		t.Fatalf("expected keys %v. got %v", keys, got)
+	}
+
+	var a int64
+	err = f.Get("a", &a)
+	if err != nil {
+		t.Fatalf("could not get value: %v", err)
+	}
+	if a != 2 {
+		t.Fatalf("expected a=2. got %d", a)
+	}
+
+	var table Table
+	err = f.Get("t2", &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer table.Close()
+
+	if table.Entries() != int64(nentries) {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries, table.Entries())
+	}
+	for i := 0; i < nentries; i++ {
+		var data tableData
+		err = table.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read entry [%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newTableData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newTableData(i), data)
+		}
+	}
+}
+
+func fileSize(t *testing.T, fname string) int64 {
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatalf("could not stat file [%s]: %v", fname, err)
+	}
+	return fi.Size()
+}
+
+func TestCompact(t *testing.T) {
+	const src = "testdata/compact-src.hio"
+	const dst = "testdata/compact-dst.hio"
+	const nentries = 50
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	testCompactCreate(t, src, nentries)
+
+	err := Compact(src, dst)
+	if err != nil {
+		t.Fatalf("could not compact file: %v", err)
+	}
+
+	if n, m := fileSize(t, src), fileSize(t, dst); m >= n {
+		t.Fatalf("expected compacted file to be smaller: %d >= %d", m, n)
+	}
+	testCompactCheck(t, dst, nentries, []string{"a", "t2"})
+
+	err = Compact(src, src)
+	if err == nil {
+		t.Fatalf("expected an error compacting a file into itself")
+	}
+}
+
+func TestFileCompact(t *testing.T) {
+	const fname = "testdata/file-compact.hio"
+	const nentries = 50
+	defer os.RemoveAll(fname)
+
+	testCompactCreate(t, fname, nentries)
+	size := fileSize(t, fname)
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		err = f.Compact()
+		if err != nil {
+			t.Fatalf("could not compact file: %v", err)
+		}
+
+		// the file is still usable.
+		c := int64(3)
+		err = f.Set("c", &c)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+		var table Table
+		err = f.Get("t2", &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table: %v", err)
+		}
+		for i := 0; i < nentries; i++ {
+			data := newTableData(i)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+	}()
+
+	func() {
+		f, err := Open(fname)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer f.Close()
+
+		var table Table
+		err = f.Get("t2", &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table: %v", err)
+		}
+		defer table.Close()
+		if table.Entries() != 2*nentries {
+			t.Fatalf("expected [%d] entries. got [%d]", 2*nentries, table.Entries())
+		}
+	}()
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		for _, name := range []string{"c", "t2"} {
+			err = f.Del(name)
+			if err != nil {
+				t.Fatalf("could not delete key [%s]: %v", name, err)
+			}
+		}
+		err = f.Compact()
+		if err != nil {
+			t.Fatalf("could not compact file: %v", err)
+		}
+	}()
+
+	if n := fileSize(t, fname); n >= size {
+		t.Fatalf("expected compacted file to be smaller: %d >= %d", n, size)
+	}
+}
+
+func TestFileDelTable(t *testing.T) {
+	const fname = "testdata/file-del-table.hio"
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		for _, name := range []string{"t1", "t2"} {
+			table, err := NewTable(f, name, WithAsync(4))
+			if err != nil {
+				t.Fatalf("could not create table [%s]: %v", name, err)
+			}
+			for i := 0; i < 10; i++ {
+				data := newTableData(i)
+				err = table.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write entry [%d]: %v", i, err)
+				}
+			}
+		}
+
+		err = f.Del("t1")
+		if err != nil {
+			t.Fatalf("could not delete table: %v", err)
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	if got, want := f.Keys(), []string{"t2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected keys %v. got %v", want, got)
+	}
+}
+
+// EOF
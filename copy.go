@@ -0,0 +1,346 @@
+package hio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CopyKey copies the named key of f to the file dst, which must be open for
+// writing.
+//
+// The records of the key are transferred as they are stored on file, still
+// encoded and compressed: copying does not need the Go type of the value
+// and does not decode it. Tables are copied with CopyTable.
+// Previous cycles of a value are keys of their own, as in "calib;1".
+// f must be open for reading, and dst must not already hold the key.
+func (f *File) CopyKey(dst *File, name string) error {
+	name = f.resolve(name)
+	err := f.checkCopy(dst, name)
+	if err != nil {
+		return err
+	}
+
+	if f.isTable(name) {
+		return f.CopyTable(dst, name)
+	}
+
+	i := f.footer.getidx(name)
+	if i < 0 {
+		return fmt.Errorf("hio: no such key [%s] in footer of file [%s]", name, f.Name())
+	}
+
+	recname, _ := splitCycle(name)
+	buf, err := f.rawRecord(recname, f.footer.Keys[i].Pos)
+	if err != nil {
+		return err
+	}
+
+	m, ok := f.meta[name]
+	if !ok {
+		m = keyMeta{Name: name}
+	}
+	return dst.addRaw(name, buf, m)
+}
+
+// addRaw appends the record buf of the named value, read with rawRecord,
+// to the file, described by m.
+func (f *File) addRaw(name string, buf []byte, m keyMeta) error {
+	pos, err := f.writeRaw(buf)
+	if err != nil {
+		return err
+	}
+
+	err = f.dict.Set(name, nil)
+	if err != nil {
+		return err
+	}
+	f.footer.Keys = append(f.footer.Keys, fileEntry{Name: name, Pos: pos, Len: int64(len(buf))})
+	f.meta[name] = m
+	return nil
+}
+
+// CopyTable copies the named table of f to the file dst, which must be open
+// for writing.
+//
+// The header of the table is copied, and its entries are transferred as
+// they are stored on file, still encoded and compressed, their offsets
+// being updated in the index of the new table.
+// f must be open for reading, and dst must not already hold the table.
+func (f *File) CopyTable(dst *File, name string) error {
+	err := f.checkCopy(dst, name)
+	if err != nil {
+		return err
+	}
+
+	var src Table
+	err = f.Get(name, &src)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if n := src.hdr.Entries; n > 0 && !src.indexed(n-1) {
+		return fmt.Errorf("hio: table [%s] of file [%s] has no index", name, f.Name())
+	}
+
+	table, err := dst.newTableLike(&src)
+	if err != nil {
+		return err
+	}
+
+	err = table.appendTable(f, &src)
+	if err != nil {
+		return err
+	}
+
+	// keep the write time of the original table.
+	if m, ok := f.meta[name]; ok {
+		meta := dst.meta[name]
+		meta.Time = m.Time
+		meta.Entries = table.hdr.Entries
+		dst.meta[name] = meta
+	}
+	return nil
+}
+
+// checkCopy checks the named key of f can be copied to dst.
+func (f *File) checkCopy(dst *File, name string) error {
+	switch {
+	case f.mode == "w":
+		return fmt.Errorf("hio: keys can only be copied from files open for reading (file [%s])", f.Name())
+	case dst.mode != "w":
+		return fmt.Errorf("hio: keys can only be copied to writable files (file [%s])", dst.Name())
+	case !f.Has(name):
+		return fmt.Errorf("hio: no such key [%s] in file [%s]", name, f.Name())
+	case dst.dict.Has(name):
+		return fmt.Errorf("hio: key [%s] already exists in file [%s]", name, dst.Name())
+	}
+	return nil
+}
+
+// newTableLike creates a new table of f with the name, type of entries and
+// layout of table ref.
+func (f *File) newTableLike(ref *Table) (*Table, error) {
+	table := &Table{}
+	err := table.init(f, ref.hdr.Name, ref.hdr.Type, []TableOption{
+		func(table *Table) {
+			table.hdr.Cluster = ref.hdr.Cluster
+			table.hdr.Codec = ref.hdr.Codec
+			table.hdr.Level = ref.hdr.Level
+			table.hdr.Basket = ref.hdr.Basket
+			table.hdr.BasketBytes = ref.hdr.BasketBytes
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// appendTable copies the entries of table t of file src at the end of the
+// table.
+func (table *Table) appendTable(src *File, t *Table) error {
+	f := table.file
+	if t.hdr.Entries == 0 {
+		return nil
+	}
+
+	switch {
+	case t.hdr.Cluster > 0:
+		table.idx.Columns = t.idx.Columns
+		for _, cluster := range t.idx.Clusters {
+			c := clusterIndex{
+				Entries: cluster.Entries,
+				Offsets: make([]int64, len(cluster.Offsets)),
+				Stats:   cluster.Stats,
+			}
+			for j, col := range t.idx.Columns {
+				pos, n, err := f.copyRecord(src, colrecname(t.hdr.Name, col), cluster.Offsets[j])
+				if err != nil {
+					return err
+				}
+				c.Offsets[j] = pos
+				table.nbytes += n
+			}
+			table.idx.Clusters = append(table.idx.Clusters, c)
+		}
+
+	case t.basketed():
+		for _, basket := range t.idx.Baskets {
+			pos, n, err := f.copyRecord(src, bktrecname(t.hdr.Name), basket.Offset)
+			if err != nil {
+				return err
+			}
+			table.idx.Baskets = append(table.idx.Baskets, basketIndex{Entries: basket.Entries, Offset: pos})
+			table.nbytes += n
+		}
+
+	default:
+		if len(table.idx.Columns) == 0 && len(t.idx.Columns) > 0 {
+			table.lock()
+			err := table.storeFields(t.idx.Columns)
+			table.unlock()
+			if err != nil {
+				return err
+			}
+		}
+		for _, off := range t.idx.Offsets {
+			pos, n, err := f.copyRecord(src, t.hdr.Name, off)
+			if err != nil {
+				return err
+			}
+			table.idx.Offsets = append(table.idx.Offsets, pos)
+			table.nbytes += n
+		}
+	}
+
+	table.hdr.Entries += t.hdr.Entries
+	if m, ok := src.meta[t.hdr.Name]; ok {
+		table.rawbytes += m.RawSize
+		if table.etype == "" {
+			table.etype = m.Type
+			table.fields = m.Fields
+		}
+	}
+	return nil
+}
+
+// rawRecord returns the bytes of the record named recname at position pos,
+// as stored on file: still encoded and compressed.
+func (f *File) rawRecord(recname string, pos int64) ([]byte, error) {
+	sr, err := f.rawSection(recname, pos)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, sr.Size())
+	_, err = sr.ReadAt(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// rawSection returns a reader of the record named recname at position pos.
+//
+// The record is located from its header, read directly from file: it is
+// never read through the stream of f, whose records may be connected to
+// values of the user, which rio would decode it into.
+func (f *File) rawSection(recname string, pos int64) (*io.SectionReader, error) {
+	r, err := f.rawReader()
+	if err != nil {
+		return nil, err
+	}
+
+	name, n, err := recordSize(r, pos)
+	if err != nil {
+		return nil, fmt.Errorf("hio: invalid record at offset %d on file [%s]: %v", pos, f.Name(), err)
+	}
+	if name != recname {
+		return nil, fmt.Errorf(
+			"hio: invalid record [%s] at offset %d on file [%s] (expected [%s])",
+			name, pos, f.Name(), recname,
+		)
+	}
+	return io.NewSectionReader(r, pos, n), nil
+}
+
+// rawReader returns the file opened for raw reads of its records.
+func (f *File) rawReader() (*os.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.raw == nil {
+		var err error
+		f.raw, err = os.Open(f.Name())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f.raw, nil
+}
+
+// rioRecordFrame marks the header of rio records.
+const rioRecordFrame = 0xabadcafe
+
+// recordSize returns the name and the size on file of the rio record at
+// position pos of r, reading its header only.
+//
+// The header of a record holds, as big-endian uint32 words, its length,
+// the record frame, the options of the record and the lengths of the
+// compressed and uncompressed payload, followed by the name of the record
+// (its length, then its bytes padded to 4 bytes).
+// The payload follows the header, padded to 4 bytes.
+func recordSize(r io.ReaderAt, pos int64) (string, int64, error) {
+	var hdr [6 * 4]byte
+	_, err := r.ReadAt(hdr[:], pos)
+	if err != nil {
+		return "", 0, err
+	}
+	word := func(i int) int64 {
+		return int64(binary.BigEndian.Uint32(hdr[4*i:]))
+	}
+
+	hlen := word(0)
+	nlen := word(5)
+	if word(1) != rioRecordFrame || hlen != int64(len(hdr))+align4(nlen) {
+		return "", 0, fmt.Errorf("hio: no rio record header")
+	}
+
+	name := make([]byte, nlen)
+	_, err = r.ReadAt(name, pos+int64(len(hdr)))
+	if err != nil {
+		return "", 0, err
+	}
+	return string(name), hlen + align4(word(3)), nil
+}
+
+// align4 returns n rounded up to a multiple of 4.
+func align4(n int64) int64 {
+	return (n + 3) &^ 3
+}
+
+// writeRaw appends the bytes of a record read with rawRecord to the file,
+// and returns its position.
+func (f *File) writeRaw(buf []byte) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var err error
+	if f.raw == nil {
+		f.raw, err = os.OpenFile(f.Name(), os.O_WRONLY, 0)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	pos := f.f.CurPos()
+	_, err = f.raw.WriteAt(buf, pos)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = f.f.Seek(int64(len(buf)), io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return pos, nil
+}
+
+// copyRecord copies the record named recname at position pos of src to the
+// end of f, and returns its position in f and its size.
+func (f *File) copyRecord(src *File, recname string, pos int64) (int64, int64, error) {
+	buf, err := src.rawRecord(recname, pos)
+	if err != nil {
+		return 0, 0, err
+	}
+	pos, err = f.writeRaw(buf)
+	if err != nil {
+		return 0, 0, err
+	}
+	return pos, int64(len(buf)), nil
+}
+
+// EOF
@@ -0,0 +1,240 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFileCopyKey(t *testing.T) {
+	const src = "testdata/copy-src.hio"
+	const dst = "testdata/copy-dst.hio"
+	const nentries = 25
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	func() {
+		f, err := Create(src)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", src, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", src, err)
+			}
+		}()
+
+		for _, v := range g_table {
+			ptr := reflect.New(reflect.TypeOf(v.value))
+			ptr.Elem().Set(reflect.ValueOf(v.value))
+			err = f.Set(v.name, ptr.Interface())
+			if err != nil {
+				t.Fatalf("could not set value [%s]: %v", v.name, err)
+			}
+		}
+
+		rows, err := NewTable(f, "rows")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		cols, err := NewTable(f, "cols", WithColumns(10), WithCompression("zlib", 9))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		bkts, err := NewTable(f, "bkts", WithBaskets(10, 0))
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		for i := 0; i < nentries; i++ {
+			data := newTableData(i)
+			err = rows.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+			col := newColData(i)
+			err = cols.Write(&col)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+			err = bkts.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+	}()
+
+	r, err := Open(src)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", src, err)
+	}
+	defer r.Close()
+
+	w, err := Create(dst)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", dst, err)
+	}
+
+	for _, name := range r.Keys() {
+		err = r.CopyKey(w, name)
+		if err != nil {
+			t.Fatalf("could not copy key [%s]: %v", name, err)
+		}
+	}
+
+	err = r.CopyKey(w, "rows")
+	if err == nil {
+		t.Fatalf("expected an error copying an existing key")
+	}
+	err = w.CopyKey(r, "rows")
+	if err == nil {
+		t.Fatalf("expected an error copying from a file open for writing")
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("could not close file [%s]: %v", dst, err)
+	}
+
+	f, err := Open(dst)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", dst, err)
+	}
+	defer f.Close()
+
+	if !reflect.DeepEqual(f.Keys(), r.Keys()) {
+		t.Fatalf("expected keys %v. got %v", r.Keys(), f.Keys())
+	}
+
+	for _, v := range g_table {
+		ptr := reflect.New(reflect.TypeOf(v.value))
+		err = f.Get(v.name, ptr.Interface())
+		if err != nil {
+			t.Fatalf("could not get value [%s]: %v", v.name, err)
+		}
+		if !reflect.DeepEqual(ptr.Elem().Interface(), v.value) {
+			t.Fatalf("%s: expected %v. got %v", v.name, v.value, ptr.Elem().Interface())
+		}
+	}
+
+	for _, test := range []struct {
+		name string
+		ref  func(i int) interface{}
+	}{
+		{"rows", func(i int) interface{} { return newTableData(i) }},
+		{"cols", func(i int) interface{} { return newColData(i) }},
+		{"bkts", func(i int) interface{} { return newTableData(i) }},
+	} {
+		func() {
+			var table Table
+			err = f.Get(test.name, &table)
+			if err != nil {
+				t.Fatalf("could not retrieve table [%s]: %v", test.name, err)
+			}
+			defer table.Close()
+
+			if n := table.Entries(); n != nentries {
+				t.Fatalf("%s: expected [%d] entries. got [%d]", test.name, nentries, n)
+			}
+
+			for i := 0; i < nentries; i++ {
+				ref := test.ref(i)
+				ptr := reflect.New(reflect.TypeOf(ref))
+				err = table.Read(ptr.Interface())
+				if err != nil {
+					t.Fatalf("%s: could not read entry [%d]: %v", test.name, i, err)
+				}
+				if !reflect.DeepEqual(ptr.Elem().Interface(), ref) {
+					t.Fatalf("%s: expected (n=%d):\nref=%v\nnew=%v", test.name, i, ref, ptr.Elem().Interface())
+				}
+			}
+		}()
+	}
+
+	want := r.KeyInfos()
+	for i, info := range f.KeyInfos() {
+		ref := want[i]
+		// only positions may change.
+		info.Pos = ref.Pos
+		if info.Table {
+			info.Size += ref.Len - info.Len
+			info.Len = ref.Len
+		}
+		if !reflect.DeepEqual(info, ref) {
+			t.Fatalf("%s: expected key info:\nref=%+v\nnew=%+v", ref.Name, ref, info)
+		}
+	}
+}
+
+func TestFileCopyKeyConnected(t *testing.T) {
+	const src = "testdata/copy-connected-src.hio"
+	const dst = "testdata/copy-connected-dst.hio"
+	defer os.RemoveAll(src)
+	defer os.RemoveAll(dst)
+
+	func() {
+		f, err := Create(src)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", src, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", src, err)
+			}
+		}()
+
+		for i := 1; i <= 2; i++ {
+			calib := int64(i)
+			err = f.Set("calib", &calib)
+			if err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+			err = f.Checkpoint()
+			if err != nil {
+				t.Fatalf("could not checkpoint file: %v", err)
+			}
+		}
+	}()
+
+	r, err := Open(src)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", src, err)
+	}
+	defer r.Close()
+
+	var cur int64
+	err = r.Get("calib", &cur)
+	if err != nil {
+		t.Fatalf("could not get value: %v", err)
+	}
+	if cur != 2 {
+		t.Fatalf("expected latest cycle [2]. got [%d]", cur)
+	}
+
+	w, err := Create(dst)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", dst, err)
+	}
+	defer w.Close()
+
+	// copying a previous cycle must not decode it into the retrieved value.
+	err = r.CopyKey(w, "calib;1")
+	if err != nil {
+		t.Fatalf("could not copy key: %v", err)
+	}
+	if cur != 2 {
+		t.Fatalf("value modified by CopyKey: expected [2]. got [%d]", cur)
+	}
+
+	var again int64
+	err = r.Get("calib", &again)
+	if err != nil {
+		t.Fatalf("could not get value: %v", err)
+	}
+	if again != 2 {
+		t.Fatalf("expected latest cycle [2] after copy. got [%d]", again)
+	}
+}
+
+// EOF
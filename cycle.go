@@ -0,0 +1,141 @@
+package hio
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// splitCycle splits name into the name of a key and a cycle number, 0 if
+// name does not designate a cycle.
+func splitCycle(name string) (string, int64) {
+	i := strings.LastIndex(name, ";")
+	if i < 0 {
+		return name, 0
+	}
+	cycle, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil || cycle <= 0 {
+		return name, 0
+	}
+	return name[:i], cycle
+}
+
+// cycleName returns the name of the cycle of the named key.
+func cycleName(name string, cycle int64) string {
+	return name + ";" + strconv.FormatInt(cycle, 10)
+}
+
+// cycle returns the cycle number of the latest value of the named key.
+//
+// Values set under the name of an existing value do not replace it: each
+// value is a new cycle of the key, the previous ones being kept on file.
+// The latest cycle of the key "calib" is retrieved as "calib", and each
+// cycle n as "calib;n". Cycles are numbered from 1, the latest cycle
+// following the most recent of the previous ones.
+func (f *File) cycle(name string) int64 {
+	return f.dict.last[name] + 1
+}
+
+// resolve returns the name of the key holding the named value: the name of
+// the key itself for its latest cycle.
+func (f *File) resolve(name string) string {
+	base, cycle := splitCycle(name)
+	if cycle > 0 && f.dict.Has(base) && f.cycle(base) == cycle {
+		return base
+	}
+	return name
+}
+
+// cycles returns the names of the previous cycles of the named key, from the
+// oldest to the most recent.
+func (f *File) cycles(name string) []string {
+	type item struct {
+		name  string
+		cycle int64
+	}
+	var items []item
+	for _, k := range f.dict.Keys() {
+		if base, cycle := splitCycle(k); cycle > 0 && base == name {
+			items = append(items, item{k, cycle})
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].cycle < items[j].cycle
+	})
+
+	names := make([]string, len(items))
+	for i, item := range items {
+		names[i] = item.name
+	}
+	return names
+}
+
+// archive turns the latest value of the named key into a previous cycle,
+// writing it to file if needed.
+func (f *File) archive(name string) error {
+	cycle := f.cycle(name)
+
+	if f.tosync.has(name) {
+		// the value may have changed since it was last written, if ever.
+		entry, err := f.syncValue(name)
+		if err != nil {
+			return err
+		}
+		if i := f.footer.getidx(name); i >= 0 {
+			f.footer.Keys = append(f.footer.Keys[:i], f.footer.Keys[i+1:]...)
+		}
+		f.tosync.del(name)
+		f.footer.Keys = append(f.footer.Keys, entry)
+	}
+
+	return f.renameKey(name, cycleName(name, cycle))
+}
+
+// renameKey renames a key written to file.
+// The records of the key are left untouched.
+func (f *File) renameKey(old, name string) error {
+	v, err := f.dict.get(old)
+	if err != nil {
+		return err
+	}
+	err = f.dict.Del(old)
+	if err != nil {
+		return err
+	}
+	err = f.dict.Set(name, v)
+	if err != nil {
+		return err
+	}
+
+	if i := f.footer.getidx(old); i >= 0 {
+		f.footer.Keys[i].Name = name
+	}
+
+	delete(f.sums, old)
+	if m, ok := f.meta[old]; ok {
+		delete(f.meta, old)
+		m.Name = name
+		f.meta[name] = m
+	}
+	return nil
+}
+
+// History returns the description of the cycles of the named value, from
+// the oldest to the latest.
+// Previous cycles are named after their cycle number ("calib;1", ...) and
+// the latest cycle after the key ("calib").
+func (f *File) History(name string) ([]KeyInfo, error) {
+	if !f.dict.Has(name) {
+		return nil, fmt.Errorf("hio: no such key [%s]", name)
+	}
+
+	names := append(f.cycles(name), name)
+	infos := make([]KeyInfo, len(names))
+	for i, k := range names {
+		infos[i] = f.keyInfo(k)
+	}
+	return infos, nil
+}
+
+// EOF
@@ -0,0 +1,129 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFileCycles(t *testing.T) {
+	const fname = "testdata/file-cycles.hio"
+	const compacted = "testdata/file-cycles-compact.hio"
+	defer os.RemoveAll(fname)
+	defer os.RemoveAll(compacted)
+
+	set := func(f *File, v int64) {
+		err := f.Set("calib", &v)
+		if err != nil {
+			t.Fatalf("could not set value [%d]: %v", v, err)
+		}
+	}
+
+	update := func(fn func(f *File)) {
+		f, err := OpenFile(fname, os.O_RDWR|os.O_CREATE)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+		fn(f)
+	}
+
+	check := func(fname string, names []string, values []int64) {
+		f, err := Open(fname)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer f.Close()
+
+		if keys := f.Keys(); !reflect.DeepEqual(keys, []string{"calib"}) {
+			t.Fatalf("expected keys [calib]. got %v", keys)
+		}
+
+		infos, err := f.History("calib")
+		if err != nil {
+			t.Fatalf("could not retrieve history: %v", err)
+		}
+		var got []string
+		for _, info := range infos {
+			got = append(got, info.Name)
+		}
+		if !reflect.DeepEqual(got, names) {
+			t.Fatalf("expected cycles %v. got %v", names, got)
+		}
+
+		for i, info := range infos {
+			var v int64
+			err = f.Get(info.Name, &v)
+			if err != nil {
+				t.Fatalf("could not get [%s]: %v", info.Name, err)
+			}
+			if v != values[i] {
+				t.Fatalf("%s: expected %d. got %d", info.Name, values[i], v)
+			}
+
+			err = f.Get(cycleName("calib", info.Cycle), &v)
+			if err != nil {
+				t.Fatalf("could not get cycle [%d]: %v", info.Cycle, err)
+			}
+			if v != values[i] {
+				t.Fatalf("cycle %d: expected %d. got %d", info.Cycle, values[i], v)
+			}
+		}
+	}
+
+	update(func(f *File) {
+		set(f, 1)
+		set(f, 2)
+
+		err := f.Set("calib;3", new(int64))
+		if err == nil {
+			t.Fatalf("expected an error setting a cycle")
+		}
+	})
+	check(fname, []string{"calib;1", "calib"}, []int64{1, 2})
+
+	update(func(f *File) {
+		set(f, 3)
+		err := f.Checkpoint()
+		if err != nil {
+			t.Fatalf("could not checkpoint file: %v", err)
+		}
+		set(f, 4)
+	})
+	check(fname, []string{"calib;1", "calib;2", "calib;3", "calib"}, []int64{1, 2, 3, 4})
+
+	update(func(f *File) {
+		for _, name := range []string{"calib;4", "calib;1"} {
+			err := f.Del(name)
+			if err != nil {
+				t.Fatalf("could not delete [%s]: %v", name, err)
+			}
+		}
+	})
+	check(fname, []string{"calib;2", "calib"}, []int64{2, 3})
+
+	err := Compact(fname, compacted)
+	if err != nil {
+		t.Fatalf("could not compact file: %v", err)
+	}
+	check(compacted, []string{"calib;2", "calib"}, []int64{2, 3})
+
+	update(func(f *File) {
+		err := f.Del("calib")
+		if err != nil {
+			t.Fatalf("could not delete [calib]: %v", err)
+		}
+		if keys := f.Keys(); len(keys) != 0 {
+			t.Fatalf("expected no keys. got %v", keys)
+		}
+		set(f, 5)
+	})
+	check(fname, []string{"calib"}, []int64{5})
+}
+
+// EOF
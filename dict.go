@@ -28,11 +28,13 @@ type ditem struct {
 
 type dict struct {
 	slice []ditem
+	last  map[string]int64 // number of the most recent previous cycle of keys
 }
 
 func newdict() dict {
 	return dict{
 		slice: make([]ditem, 0),
+		last:  make(map[string]int64),
 	}
 }
 
@@ -97,6 +99,16 @@ func (d *dict) Del(name string) error {
 
 	d.slice = append(d.slice[:i], d.slice[i+1:]...)
 
+	if base, cycle := splitCycle(name); cycle > 0 && cycle == d.last[base] {
+		// look for the previous cycle left.
+		delete(d.last, base)
+		for _, item := range d.slice {
+			if b, n := splitCycle(item.k); b == base && n > d.last[base] {
+				d.last[base] = n
+			}
+		}
+	}
+
 	return err
 }
 
@@ -108,6 +120,10 @@ func (d *dict) Set(name string, v Value) error {
 	} else {
 		d.slice = append(d.slice, ditem{k: name, v: v})
 	}
+
+	if base, cycle := splitCycle(name); cycle > d.last[base] {
+		d.last[base] = cycle
+	}
 	return err
 }
 
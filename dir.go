@@ -0,0 +1,206 @@
+package hio
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Dir is a directory of a File: the keys of the directory "run1/muons" are
+// the keys of the file named "run1/muons/<name>".
+//
+// Directories may be nested. Directories made with Mkdir are recorded in
+// the footer of the file, so they exist even when empty; the directories
+// along the path of a key exist as long as the key does.
+type Dir struct {
+	f    *File
+	path string
+}
+
+// Mkdir creates the directory with the provided path, along with any
+// missing parent, and returns it.
+// If the directory already exists, Mkdir returns it.
+func (f *File) Mkdir(path string) (*Dir, error) {
+	if f.mode != "w" {
+		return nil, fmt.Errorf("hio: only writable files can create directories")
+	}
+
+	p, err := cleanPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, fmt.Errorf("hio: invalid directory path [%s]", path)
+	}
+
+	elems := strings.Split(p, "/")
+	for i := range elems {
+		dir := strings.Join(elems[:i+1], "/")
+		if f.dict.Has(dir) {
+			return nil, fmt.Errorf("hio: key [%s] is not a directory", dir)
+		}
+		j := sort.SearchStrings(f.dirs, dir)
+		if j < len(f.dirs) && f.dirs[j] == dir {
+			continue
+		}
+		f.dirs = append(f.dirs, "")
+		copy(f.dirs[j+1:], f.dirs[j:])
+		f.dirs[j] = dir
+	}
+
+	return &Dir{f: f, path: p}, nil
+}
+
+// Dir returns the directory with the provided path.
+// The empty path designates the root directory of the file.
+func (f *File) Dir(path string) (*Dir, error) {
+	p, err := cleanPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if p != "" && !f.isDir(p) {
+		return nil, fmt.Errorf("hio: no such directory [%s]", path)
+	}
+	return &Dir{f: f, path: p}, nil
+}
+
+// isDir returns whether the provided path designates a directory.
+func (f *File) isDir(p string) bool {
+	i := sort.SearchStrings(f.dirs, p)
+	if i < len(f.dirs) && f.dirs[i] == p {
+		return true
+	}
+	prefix := p + "/"
+	for _, k := range f.dict.Keys() {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// rmdir removes the directory with the provided path, and its content.
+func (f *File) rmdir(p string) error {
+	prefix := p + "/"
+	for _, k := range f.dict.Keys() {
+		if !strings.HasPrefix(k, prefix) || !f.dict.Has(k) {
+			// cycles are removed along with their key.
+			continue
+		}
+		err := f.Del(k)
+		if err != nil {
+			return err
+		}
+	}
+
+	dirs := f.dirs[:0]
+	for _, dir := range f.dirs {
+		if dir != p && !strings.HasPrefix(dir, prefix) {
+			dirs = append(dirs, dir)
+		}
+	}
+	f.dirs = dirs
+	return nil
+}
+
+// cleanPath returns the canonical form of the path of a directory, without
+// leading nor trailing slashes.
+func cleanPath(p string) (string, error) {
+	clean := strings.Trim(p, "/")
+	if clean == "" {
+		return "", nil
+	}
+	for _, elem := range strings.Split(clean, "/") {
+		if elem == "" || elem == "." || elem == ".." || strings.Contains(elem, ";") {
+			return "", fmt.Errorf("hio: invalid directory path [%s]", p)
+		}
+	}
+	return clean, nil
+}
+
+// Path returns the path of the directory in the file.
+func (d *Dir) Path() string {
+	return d.path
+}
+
+// Name returns the name of the directory: the last element of its path.
+func (d *Dir) Name() string {
+	return path.Base("/" + d.path)
+}
+
+// key returns the name in the file of the named key of the directory.
+func (d *Dir) key(name string) string {
+	if d.path == "" {
+		return name
+	}
+	return d.path + "/" + name
+}
+
+// Mkdir creates the named sub-directory, along with any missing parent, and
+// returns it.
+func (d *Dir) Mkdir(name string) (*Dir, error) {
+	return d.f.Mkdir(d.key(name))
+}
+
+// Dir returns the named sub-directory.
+func (d *Dir) Dir(name string) (*Dir, error) {
+	return d.f.Dir(d.key(name))
+}
+
+func (d *Dir) Get(name string, v Value) error {
+	return d.f.Get(d.key(name), v)
+}
+
+func (d *Dir) Has(name string) bool {
+	return d.f.Has(d.key(name))
+}
+
+// Del removes the named key, or sub-directory with all its content, from
+// the directory.
+func (d *Dir) Del(name string) error {
+	return d.f.Del(d.key(name))
+}
+
+func (d *Dir) Set(name string, v Value) error {
+	return d.f.Set(d.key(name), v)
+}
+
+// Keys returns the names of the keys and sub-directories of the directory.
+func (d *Dir) Keys() []string {
+	prefix := ""
+	if d.path != "" {
+		prefix = d.path + "/"
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	add := func(name string) {
+		if !strings.HasPrefix(name, prefix) || name == prefix {
+			return
+		}
+		name = name[len(prefix):]
+		if i := strings.Index(name, "/"); i >= 0 {
+			name = name[:i]
+		}
+		if !seen[name] {
+			seen[name] = true
+			keys = append(keys, name)
+		}
+	}
+
+	for _, k := range d.f.Keys() {
+		add(k)
+	}
+	for _, dir := range d.f.dirs {
+		add(dir)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// check interfaces
+var _ Dict = (*Dir)(nil)
+
+// EOF
@@ -0,0 +1,164 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFileDirs(t *testing.T) {
+	const fname = "testdata/file-dirs.hio"
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		muons, err := f.Mkdir("run1/muons")
+		if err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		if muons.Path() != "run1/muons" || muons.Name() != "muons" {
+			t.Fatalf("invalid directory [%s] (name=%q)", muons.Path(), muons.Name())
+		}
+
+		pt := int64(42)
+		err = muons.Set("pt", &pt)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+
+		table, err := NewTable(f, "run1/events")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		data := newTableData(0)
+		err = table.Write(&data)
+		if err != nil {
+			t.Fatalf("could not write entry: %v", err)
+		}
+
+		_, err = f.Mkdir("run2/empty")
+		if err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+		top := int64(1)
+		err = f.Set("top", &top)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+
+		for _, path := range []string{"top", "run1/muons/pt", "run1/../x", ""} {
+			_, err = f.Mkdir(path)
+			if err == nil {
+				t.Fatalf("expected an error creating directory [%s]", path)
+			}
+		}
+		err = f.Set("run1", &top)
+		if err == nil {
+			t.Fatalf("expected an error setting a value over a directory")
+		}
+	}()
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		for _, test := range []struct {
+			path string
+			keys []string
+		}{
+			{"", []string{"run1", "run2", "top"}},
+			{"/run1/", []string{"events", "muons"}},
+			{"run1/muons", []string{"pt"}},
+			{"run2", []string{"empty"}},
+			{"run2/empty", nil},
+		} {
+			dir, err := f.Dir(test.path)
+			if err != nil {
+				t.Fatalf("could not retrieve directory [%s]: %v", test.path, err)
+			}
+			if keys := dir.Keys(); !reflect.DeepEqual(keys, test.keys) {
+				t.Fatalf("%s: expected keys %v. got %v", test.path, test.keys, keys)
+			}
+		}
+
+		_, err = f.Dir("run3")
+		if err == nil {
+			t.Fatalf("expected an error retrieving a missing directory")
+		}
+
+		run1, err := f.Dir("run1")
+		if err != nil {
+			t.Fatalf("could not retrieve directory: %v", err)
+		}
+		muons, err := run1.Dir("muons")
+		if err != nil {
+			t.Fatalf("could not retrieve directory: %v", err)
+		}
+		if !muons.Has("pt") {
+			t.Fatalf("expected key [pt] in directory [%s]", muons.Path())
+		}
+		var pt int64
+		err = muons.Get("pt", &pt)
+		if err != nil {
+			t.Fatalf("could not get value: %v", err)
+		}
+		if pt != 42 {
+			t.Fatalf("expected pt=42. got %d", pt)
+		}
+
+		var table Table
+		err = run1.Get("events", &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table: %v", err)
+		}
+		if table.Entries() != 1 {
+			t.Fatalf("expected [1] entry. got [%d]", table.Entries())
+		}
+
+		err = run1.Del("muons")
+		if err != nil {
+			t.Fatalf("could not delete directory: %v", err)
+		}
+		err = f.Del("run2")
+		if err != nil {
+			t.Fatalf("could not delete directory: %v", err)
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	if keys, want := f.Keys(), []string{"run1/events", "top"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected keys %v. got %v", want, keys)
+	}
+	root, err := f.Dir("")
+	if err != nil {
+		t.Fatalf("could not retrieve root directory: %v", err)
+	}
+	if keys, want := root.Keys(), []string{"run1", "top"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected keys %v. got %v", want, keys)
+	}
+}
+
+// EOF
@@ -1,13 +1,27 @@
 package hio
 
 import (
+	"encoding/binary"
 	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-hep/rio"
 )
 
+// File is a hio file, holding any mix of named tables and values
+// (histograms, structs, ...).
 type File struct {
+	name   string
 	f      *rio.Stream
 	mode   string
 	header FileHeader
@@ -16,6 +30,20 @@ type File struct {
 	begin  int64 // start of file payload
 	tosync pmap
 	tables pmap
+	meta   map[string]keyMeta // description of keys
+	dirs   []string           // sorted paths of the directories made with Mkdir
+	sums   map[string]uint64  // checksums of the values to sync, as last written
+	ckpt   checkpoint
+	mu     sync.Mutex // serializes accesses to the stream by asynchronous tables
+	raw    *os.File   // raw access to the records, opened on demand
+}
+
+// checkpoint holds the state of automatic checkpoints.
+type checkpoint struct {
+	everyRecs  int64 // number of entries between checkpoints
+	everyBytes int64 // number of bytes between checkpoints
+	nrecs      int64 // number of entries since last checkpoint
+	pos        int64 // position of the end of last checkpoint
 }
 
 func Open(fname string) (*File, error) {
@@ -29,14 +57,25 @@ func Open(fname string) (*File, error) {
 		return nil, err
 	}
 
+	err = fh.check(fname)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
 	begin := f.CurPos()
 
+	if fh.Pos <= 0 {
+		f.Close()
+		return nil, fmt.Errorf("hio: file [%s] has no footer (use Recover to rebuild it)", fname)
+	}
+
 	_, err = f.Seek(fh.Pos, 0)
 	if err != nil {
 		return nil, err
 	}
 
-	ft, err := newFileFooterFrom(f)
+	ft, meta, dirs, err := newFileFooterFrom(f)
 	if err != nil {
 		return nil, err
 	}
@@ -47,6 +86,7 @@ func Open(fname string) (*File, error) {
 	}
 
 	hfile := &File{
+		name:   fname,
 		f:      f,
 		mode:   "r",
 		header: fh,
@@ -55,11 +95,16 @@ func Open(fname string) (*File, error) {
 		begin:  begin,
 		tosync: newpmap(),
 		tables: newpmap(),
+		meta:   make(map[string]keyMeta, len(meta)),
+		dirs:   dirs,
 	}
 
 	for _, key := range hfile.footer.Keys {
 		hfile.dict.Set(key.Name, nil)
 	}
+	for _, m := range meta {
+		hfile.meta[m.Name] = m
+	}
 	return hfile, err
 }
 
@@ -70,6 +115,7 @@ func Create(fname string) (*File, error) {
 	}
 
 	hfile := &File{
+		name: fname,
 		f:    f,
 		mode: "w",
 		header: FileHeader{
@@ -81,6 +127,7 @@ func Create(fname string) (*File, error) {
 		dict:   newdict(),
 		tosync: newpmap(),
 		tables: newpmap(),
+		meta:   make(map[string]keyMeta),
 	}
 
 	rec := hfile.f.Record("hio.FileHeader")
@@ -104,9 +151,190 @@ func Create(fname string) (*File, error) {
 	return hfile, err
 }
 
+// OpenFile opens the named file with the specified flag (os.O_RDONLY,
+// os.O_RDWR, os.O_CREATE, os.O_TRUNC, ...).
+//
+// A file opened with os.O_RDWR is writable: new keys may be added, existing
+// keys modified or deleted, and existing tables extended.
+// FileHeader.Pos and the footer are rewritten on Close.
+//
+// rio can not open existing streams for writing, so opening a file for
+// update copies it whole into a temporary file of the same directory, which
+// then replaces it: this costs a read and a write of the file, and as much
+// free disk space as its size. The file is left untouched if the copy fails.
+func OpenFile(fname string, flag int) (*File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return Open(fname)
+	}
+
+	if flag&os.O_TRUNC != 0 {
+		return Create(fname)
+	}
+
+	_, err := os.Stat(fname)
+	if os.IsNotExist(err) && flag&os.O_CREATE != 0 {
+		return Create(fname)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return openUpdate(fname)
+}
+
+// openUpdate opens an existing file for update.
+func openUpdate(fname string) (*File, error) {
+	r, err := Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	hdr := r.header
+	ftr := r.footer
+	meta := r.meta
+	dirs := r.dirs
+	begin := r.begin
+
+	// new records are written after the footer, which stays valid until
+	// the next one is written by Checkpoint or Close.
+	_, err = r.f.Seek(hdr.Pos, 0)
+	if err == nil {
+		_, err = r.f.ReadRecord()
+	}
+	end := r.f.CurPos()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	err = r.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := reopen(fname, hdr, ftr, meta, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	f.dirs = dirs
+	return f, nil
+}
+
+// reopen opens an existing file in write-mode with the provided header,
+// footer and description of keys, positioning the stream at end.
+//
+// rio can only create streams for writing, so the existing payload is
+// copied into a fresh stream, created in the directory of the file with a
+// unique name and the mode of the file, which then replaces it. The file is
+// left untouched until it is replaced: it survives any failure, the copy
+// being removed.
+func reopen(fname string, hdr FileHeader, ftr FileFooter, meta map[string]keyMeta, begin, end int64) (*File, error) {
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmp := w.Name()
+	err = w.Chmod(fi.Mode().Perm())
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		w.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	f, err := rio.Create(tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	hfile, err := func() (*File, error) {
+		err := copyFile(tmp, fname)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = f.Seek(end, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		hfile := &File{
+			name:   fname,
+			f:      f,
+			mode:   "w",
+			header: hdr,
+			footer: ftr,
+			dict:   newdict(),
+			begin:  begin,
+			tosync: newpmap(),
+			tables: newpmap(),
+			meta:   meta,
+		}
+		if hfile.meta == nil {
+			hfile.meta = make(map[string]keyMeta)
+		}
+		// records written from now on are of the current version.
+		hfile.header.Version = g_version
+
+		for _, key := range hfile.footer.Keys {
+			hfile.dict.Set(key.Name, nil)
+		}
+
+		rec := hfile.f.Record("hio.FileHeader")
+		err = rec.Connect("hio.FileHeader", &hfile.header)
+		if err != nil {
+			return nil, err
+		}
+
+		rec = hfile.f.Record("hio.FileFooter")
+		err = rec.Connect("hio.FileFooter", &hfile.footer)
+		if err != nil {
+			return nil, err
+		}
+
+		return hfile, os.Rename(tmp, fname)
+	}()
+	if err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	return hfile, nil
+}
+
+// copyFile copies the content of the src file into the dst file.
+func copyFile(dst, src string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
 // Name returns the name of the file
 func (f *File) Name() string {
-	return f.f.Name()
+	return f.name
 }
 
 // Fd returns the integer Unix file descriptor referencing the open file.
@@ -117,6 +345,40 @@ func (f *File) Fd() uintptr {
 // Close closes the File, rendering it unusable for I/O.
 // It returns an error, if any
 func (f *File) Close() error {
+	err := f.close()
+
+	// release the background goroutines and the streams of the file, even
+	// if it could not be written.
+	for _, k := range f.tables.keys() {
+		v, e := f.dict.get(k)
+		if e != nil {
+			continue
+		}
+		e = v.(*Table).stop()
+		if err == nil {
+			err = e
+		}
+	}
+
+	if f.raw != nil {
+		e := f.raw.Close()
+		if err == nil {
+			err = e
+		}
+		f.raw = nil
+	}
+
+	e := f.f.Close()
+	if err == nil {
+		err = e
+	}
+
+	return err
+}
+
+// close writes the footer of a file open for writing, and commits it to
+// stable storage.
+func (f *File) close() error {
 	var err error
 	err = f.Sync()
 	if err != nil {
@@ -125,133 +387,424 @@ func (f *File) Close() error {
 
 	// if file opened in write-mode, write header back
 	if f.mode == "w" {
+		err = f.writeFooter()
+		if err != nil {
+			return err
+		}
 
-		curpos := f.f.CurPos()
-		entries := make([]fileEntry, 0, f.tosync.Len()+f.tables.Len())
-		for _, item := range f.tables.slice {
-			k := item.k
-			pos := item.v
-			hdr := "hio.Header/" + k
-			rec := f.f.Record(hdr)
-			if rec == nil {
-				err = fmt.Errorf("hio: could not retrieve [%s] record", k)
-				return err
-			}
+		// discard anything past the footer, such as a record truncated by a
+		// crash (Recover).
+		err = os.Truncate(f.Name(), f.f.CurPos())
+		if err != nil {
+			return err
+		}
 
-			v, err := f.dict.get(k)
-			if err != nil {
-				return err
-			}
+		err = f.Sync()
+		if err != nil {
+			return err
+		}
+	}
 
-			table := v.(*Table)
-			err = rec.Connect(hdr, &table.hdr)
-			if err != nil && err != rio.ErrBlockConnected {
-				return err
-			}
+	return err
+}
 
-			_, err = f.f.Seek(pos, 0)
-			if err != nil {
-				return err
-			}
+// Checkpoint makes the current content of the file durable: table headers,
+// values set so far and a provisional footer are written to file, and
+// FileHeader.Pos is updated to point at that footer.
+// Only the values changed since the previous checkpoint are written again,
+// and only the part of the table indices added since then.
+// If the writer dies afterwards, the file can still be opened and holds
+// everything written up to the last checkpoint.
+func (f *File) Checkpoint() error {
+	if f.mode != "w" {
+		return fmt.Errorf("hio: only writable files can be checkpointed")
+	}
 
-			err = f.f.WriteRecord(rec)
-			if err != nil {
-				return err
-			}
+	err := f.writeFooter()
+	if err != nil {
+		return err
+	}
 
-			entries = append(entries,
-				fileEntry{
-					Name: k,
-					Pos:  pos,
-					Len:  f.f.CurPos() - pos,
-				},
-			)
+	f.ckpt.nrecs = 0
+	f.ckpt.pos = f.f.CurPos()
+
+	return f.Sync()
+}
+
+// SetCheckpoint enables automatic checkpoints: the file is checkpointed every
+// nrecs table entries or every nbytes bytes written, whichever comes first.
+// A zero value disables the corresponding trigger.
+func (f *File) SetCheckpoint(nrecs, nbytes int64) {
+	f.ckpt.everyRecs = nrecs
+	f.ckpt.everyBytes = nbytes
+}
+
+// written is called by tables each time an entry has been written to file.
+// written checkpoints the file when needed.
+func (f *File) written() error {
+	f.ckpt.nrecs++
+	switch {
+	case f.ckpt.everyRecs > 0 && f.ckpt.nrecs >= f.ckpt.everyRecs:
+		return f.Checkpoint()
+	case f.ckpt.everyBytes > 0 && f.curpos()-f.ckpt.pos >= f.ckpt.everyBytes:
+		return f.Checkpoint()
+	}
+	return nil
+}
+
+// curpos returns the current position of the stream being written.
+func (f *File) curpos() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.f.CurPos()
+}
+
+// writeFooter writes the table headers in place, the values to sync and the
+// footer at the current position, and updates FileHeader.Pos.
+// The stream is left positioned right after the footer.
+func (f *File) writeFooter() error {
+	var err error
+	for _, k := range f.tables.keys() {
+		v, err := f.dict.get(k)
+		if err != nil {
+			return err
 		}
-		_, err = f.f.Seek(curpos, 0)
+		err = v.(*Table).flush()
 		if err != nil {
 			return err
 		}
+	}
 
-		for _, k := range f.tosync.keys() {
-			rec := f.f.Record(k)
-			if rec == nil {
-				err = fmt.Errorf("hio: could not retrieve [%s] record", k)
-				return err
-			}
+	curpos := f.f.CurPos()
+	entries := make([]fileEntry, 0, f.tosync.Len()+f.tables.Len())
+	for _, item := range f.tables.slice {
+		k := item.k
+		pos := item.v
+		hdr := "hio.Header/" + k
+		rec := f.f.Record(hdr)
+		if rec == nil {
+			err = fmt.Errorf("hio: could not retrieve [%s] record", k)
+			return err
+		}
 
-			pos := f.f.CurPos()
-			v, err := f.dict.get(k)
-			if err != nil {
+		v, err := f.dict.get(k)
+		if err != nil {
+			return err
+		}
+
+		table := v.(*Table)
+		err = table.hdr.connect(rec)
+		if err != nil {
+			return err
+		}
+
+		// write the part of the entries index added since the previous
+		// checkpoint at the end of file, and the header pointing at it in
+		// place.
+		if n := table.idx.count(); n != table.saved || table.hdr.Index == 0 {
+			chunk := indexChunk{
+				Prev:  table.hdr.Index,
+				Index: table.idx.since(table.saved),
+			}
+			idxname := "hio.Index/" + k
+			idx := f.f.Record(idxname)
+			err = idx.Connect(idxname, &chunk)
+			if err != nil && err != rio.ErrBlockConnected {
 				return err
 			}
-
-			err = rec.Connect(k, v)
+			_, err = f.f.Seek(curpos, 0)
 			if err != nil {
 				return err
 			}
-
-			err = f.f.WriteRecord(rec)
+			table.hdr.Index = curpos
+			err = f.f.WriteRecord(idx)
 			if err != nil {
 				return err
 			}
-			entries = append(entries,
-				fileEntry{
-					Name: k,
-					Pos:  pos,
-					Len:  f.f.CurPos() - pos,
-				},
-			)
+			curpos = f.f.CurPos()
+			table.saved = n
 		}
 
-		f.header.Pos = f.f.CurPos()
-		_, err = f.f.Seek(0, 0)
+		if pos < 0 {
+			pos = curpos
+			f.tables.set(k, pos)
+		}
+		_, err = f.f.Seek(pos, 0)
 		if err != nil {
 			return err
 		}
 
-		rec := f.f.Record("hio.FileHeader")
-		if rec == nil {
-			err = fmt.Errorf("hio: could not retrieve hio.FileHeader record")
-			return err
-		}
 		err = f.f.WriteRecord(rec)
 		if err != nil {
 			return err
 		}
-
-		if f.header.Pos != 0 {
-			_, err = f.f.Seek(f.header.Pos, 0)
-			if err != nil {
-				return err
-			}
+		if pos == curpos {
+			curpos = f.f.CurPos()
 		}
 
-		rec = f.f.Record("hio.FileFooter")
-		if rec == nil {
-			err = fmt.Errorf("hio: could not retrieve hio.FileHeader record")
-			return err
+		entries = append(entries,
+			fileEntry{
+				Name: k,
+				Pos:  pos,
+				Len:  f.f.CurPos() - pos,
+			},
+		)
+
+		m := f.meta[k]
+		m.Name = k
+		m.Table = true
+		switch {
+		case table.hdr.Type != "":
+			m.Type = table.hdr.Type
+		case table.etype != "":
+			m.Type = table.etype
 		}
-		f.footer.Keys = append(f.footer.Keys, entries...)
+		if table.fields != nil {
+			m.Fields = table.fields
+		}
+		if m.Time == 0 || m.Entries != table.hdr.Entries {
+			m.Time = time.Now().UnixNano()
+		}
+		m.Entries = table.hdr.Entries
+		m.Codec = table.hdr.Codec
+		m.Bytes = table.nbytes
+		m.RawSize = table.rawbytes
+		f.meta[k] = m
+	}
+	_, err = f.f.Seek(curpos, 0)
+	if err != nil {
+		return err
+	}
 
-		err = f.f.WriteRecord(rec)
+	for _, k := range f.tosync.keys() {
+		entry, err := f.syncValue(k)
 		if err != nil {
 			return err
 		}
+		entries = append(entries, entry)
+	}
 
-		err = f.Sync()
-		if err != nil {
-			return err
+	rec := f.f.Record("hio.FileFooter")
+	if rec == nil {
+		err = fmt.Errorf("hio: could not retrieve hio.FileFooter record")
+		return err
+	}
+	keys := make([]fileEntry, 0, len(f.footer.Keys)+len(entries))
+	for _, key := range f.footer.Keys {
+		// drop keys which have been deleted or rewritten since Open.
+		if !f.dict.Has(key.Name) || f.tables.has(key.Name) || f.tosync.has(key.Name) {
+			continue
 		}
+		keys = append(keys, key)
+	}
+	f.footer.Keys = append(keys, entries...)
+
+	meta := f.metas()
+	err = rec.Connect("hio.KeyMeta", &meta)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+	err = rec.Connect("hio.Dirs", &f.dirs)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
 	}
 
-	err = f.f.Close()
+	// write the footer before pointing the header at it, so the file stays
+	// consistent if we die in between.
+	pos := f.f.CurPos()
+	err = f.f.WriteRecord(rec)
 	if err != nil {
 		return err
 	}
+	end := f.f.CurPos()
 
+	f.header.Pos = pos
+	_, err = f.f.Seek(0, 0)
+	if err != nil {
+		return err
+	}
+
+	rec = f.f.Record("hio.FileHeader")
+	if rec == nil {
+		err = fmt.Errorf("hio: could not retrieve hio.FileHeader record")
+		return err
+	}
+	err = f.f.WriteRecord(rec)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.f.Seek(end, 0)
 	return err
 }
 
+// syncValue writes the value of the named key at the current position,
+// unless it has not changed since it was last written, and returns its
+// entry for the footer.
+func (f *File) syncValue(k string) (fileEntry, error) {
+	v, err := f.dict.get(k)
+	if err != nil {
+		return fileEntry{}, err
+	}
+
+	sum, ok := checksum(v)
+	if prev, seen := f.sums[k]; ok && seen && prev == sum {
+		if i := f.footer.getidx(k); i >= 0 {
+			return f.footer.Keys[i], nil
+		}
+	}
+
+	entry, err := f.writeValue(k)
+	if err != nil {
+		return entry, err
+	}
+
+	if f.sums == nil {
+		f.sums = make(map[string]uint64)
+	}
+	if ok {
+		f.sums[k] = sum
+	} else {
+		delete(f.sums, k)
+	}
+	return entry, nil
+}
+
+// checksum returns a checksum of the content of v, and whether it could be
+// computed.
+func checksum(v interface{}) (uint64, bool) {
+	h := fnv.New64a()
+	ok := hashValue(h, reflect.ValueOf(v), make(map[uintptr]bool))
+	return h.Sum64(), ok
+}
+
+// hashValue writes the content of v to h, following pointers, independently
+// of the iteration order of maps.
+// hashValue returns false for values holding cycles, functions or channels.
+func hashValue(h hash.Hash64, v reflect.Value, seen map[uintptr]bool) bool {
+	var buf [8]byte
+	put := func(x uint64) {
+		binary.LittleEndian.PutUint64(buf[:], x)
+		h.Write(buf[:])
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		put(0)
+	case reflect.Bool:
+		if v.Bool() {
+			put(1)
+		} else {
+			put(0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		put(uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		put(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		put(math.Float64bits(v.Float()))
+	case reflect.Complex64, reflect.Complex128:
+		put(math.Float64bits(real(v.Complex())))
+		put(math.Float64bits(imag(v.Complex())))
+	case reflect.String:
+		put(uint64(v.Len()))
+		h.Write([]byte(v.String()))
+	case reflect.Ptr:
+		if v.IsNil() {
+			put(0)
+			return true
+		}
+		if seen[v.Pointer()] {
+			return false
+		}
+		seen[v.Pointer()] = true
+		defer delete(seen, v.Pointer())
+		put(1)
+		return hashValue(h, v.Elem(), seen)
+	case reflect.Interface:
+		if v.IsNil() {
+			put(0)
+			return true
+		}
+		h.Write([]byte(v.Elem().Type().String()))
+		return hashValue(h, v.Elem(), seen)
+	case reflect.Slice, reflect.Array:
+		put(uint64(v.Len()))
+		for i := 0; i < v.Len(); i++ {
+			if !hashValue(h, v.Index(i), seen) {
+				return false
+			}
+		}
+	case reflect.Map:
+		sums := make([]uint64, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			kv := fnv.New64a()
+			if !hashValue(kv, iter.Key(), seen) || !hashValue(kv, iter.Value(), seen) {
+				return false
+			}
+			sums = append(sums, kv.Sum64())
+		}
+		sort.Slice(sums, func(i, j int) bool { return sums[i] < sums[j] })
+		put(uint64(len(sums)))
+		for _, sum := range sums {
+			put(sum)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !hashValue(h, v.Field(i), seen) {
+				return false
+			}
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// writeValue writes the value of the named key at the current position and
+// returns its entry for the footer.
+func (f *File) writeValue(k string) (fileEntry, error) {
+	rec := f.f.Record(k)
+	if rec == nil {
+		return fileEntry{}, fmt.Errorf("hio: could not retrieve [%s] record", k)
+	}
+
+	pos := f.f.CurPos()
+	v, err := f.dict.get(k)
+	if err != nil {
+		return fileEntry{}, err
+	}
+
+	err = rec.Connect(k, v)
+	if err != nil && err != rio.ErrBlockConnected {
+		return fileEntry{}, err
+	}
+
+	// the cycle of the value, for Recover.
+	cycle := f.cycle(k)
+	err = rec.Connect("hio.Cycle", &cycle)
+	if err != nil && err != rio.ErrBlockConnected {
+		return fileEntry{}, err
+	}
+
+	err = f.f.WriteRecord(rec)
+	if err != nil {
+		return fileEntry{}, err
+	}
+
+	m := f.meta[k]
+	m.RawSize = sizeOf(reflect.ValueOf(v))
+	f.meta[k] = m
+
+	entry := fileEntry{
+		Name: k,
+		Pos:  pos,
+		Len:  f.f.CurPos() - pos,
+	}
+	return entry, nil
+}
+
 // Stat returns the FileInfo structure describing file. If there is an
 // error, it will be of type *PathError.
 func (f *File) Stat() (os.FileInfo, error) {
@@ -265,98 +818,322 @@ func (f *File) Sync() error {
 	return f.f.Sync()
 }
 
-// Keys returns the list of objects contained in the file
+// Keys returns the list of objects contained in the file.
+// Previous cycles of values are not listed (see History).
 func (f *File) Keys() []string {
-	return f.dict.Keys()
+	keys := f.dict.Keys()
+	names := keys[:0]
+	for _, k := range keys {
+		if _, cycle := splitCycle(k); cycle == 0 {
+			names = append(names, k)
+		}
+	}
+	return names
 }
 
+// Get retrieves the value of the named key into v.
+// name may designate a cycle of the value, as in "calib;1".
 func (f *File) Get(name string, v Value) error {
+	name = f.resolve(name)
+	if tt, ok := v.(typedTable); ok {
+		return f.getTyped(name, tt)
+	}
+
 	vv, err := f.dict.get(name)
 	if err != nil {
 		return err
 	}
 
 	if vv == nil {
-		if table, ok := v.(*Table); ok {
-			pos := f.f.CurPos()
-			defer f.f.Seek(pos, 0)
+		// load from file
+		err = f.load(name, v)
+		if err != nil {
+			return err
+		}
 
-			_, err = f.f.Seek(f.begin, 0)
-			if err != nil {
-				return err
-			}
+		err = f.dict.Set(name, v)
+		if err != nil {
+			return err
+		}
+	}
 
-			// load from file
-			hdrname := "hio.Header/" + name
-			rec := f.f.Record(hdrname)
-			if rec == nil {
-				return fmt.Errorf("hio: no such record [%s] on file [%s]", name, f.Name())
-			}
-			rec.SetUnpack(true)
-			err = rec.Connect(hdrname, &table.hdr)
-			if err != nil {
-				return err
-			}
-			_, err = f.f.ReadRecord()
-			if err != nil {
-				return err
-			}
+	err = f.dict.Get(name, v)
+	if err != nil {
+		return err
+	}
 
-			err = f.dict.Set(name, table)
-			if err != nil {
-				return err
+	if table, ok := v.(*Table); ok {
+		table.reset()
+		if f.mode == "w" && !f.tables.has(name) {
+			// table written during a previous session: new entries are
+			// appended to it and its header is updated on Close.
+			i := f.footer.getidx(name)
+			if i < 0 {
+				return fmt.Errorf("hio: no such key [%s] in footer of file [%s]", name, f.Name())
 			}
-		} else {
-			// load from file
-			rec := f.f.Record(name)
-			if rec == nil {
-				return fmt.Errorf("hio: no such record [%s] on file [%s]", name, f.Name())
+			f.tables.set(name, f.footer.Keys[i].Pos)
+			table.setStream(f.f)
+			table.doclose = false
+			table.file = f
+			if m, ok := f.meta[name]; ok {
+				table.nbytes = m.Bytes
+				table.rawbytes = m.RawSize
+				table.etype = m.Type
+				table.fields = m.Fields
 			}
-			rec.SetUnpack(true)
-			err = rec.Connect(name, v)
-			if err != nil {
-				return err
+			if table.hdr.Version == 0 {
+				// table written without an index: locate its entries so
+				// the index written on Close covers them too.
+				table.idx.Offsets, err = f.scanOffsets(name, table.hdr.Entries)
+				if err != nil {
+					return err
+				}
+				// the upgraded header does not fit in place of the old
+				// one: it is written at the end of file.
+				table.hdr.Version = tableVersion
+				f.tables.set(name, -1)
 			}
-			_, err = f.f.ReadRecord()
+		} else {
+			stream, err := rio.Open(f.Name())
 			if err != nil {
 				return err
 			}
+			table.setStream(stream)
+			table.doclose = true
+			table.file = nil
+		}
+	}
 
-			err = f.dict.Set(name, v)
-			if err != nil {
-				return err
-			}
+	return err
+}
+
+// load reads the value of the named key from the record located at the
+// offset recorded in the footer.
+func (f *File) load(name string, v Value) error {
+	i := f.footer.getidx(name)
+	if i < 0 {
+		return fmt.Errorf("hio: no such key [%s] in footer of file [%s]", name, f.Name())
+	}
+	key := f.footer.Keys[i]
+
+	// cycles of a value are stored in records named after the key.
+	recname, _ := splitCycle(name)
+	table, istable := v.(*Table)
+	if istable {
+		recname = "hio.Header/" + name
+	}
+
+	pos := f.f.CurPos()
+	defer f.f.Seek(pos, 0)
+
+	_, err := f.f.Seek(key.Pos, 0)
+	if err != nil {
+		return err
+	}
+
+	rec := f.f.Record(recname)
+	if rec == nil {
+		return fmt.Errorf("hio: no such record [%s] on file [%s]", recname, f.Name())
+	}
+	rec.SetUnpack(true)
+	if istable {
+		table.hdr = tableHeader{}
+		err = table.hdr.connect(rec)
+	} else {
+		err = rec.Connect(recname, v)
+	}
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+
+	rec, err = f.f.ReadRecord()
+	if err != nil {
+		return err
+	}
+
+	if rec.Name() != recname || f.f.CurPos() != key.Pos+key.Len {
+		return fmt.Errorf(
+			"hio: invalid record for key [%s] at offset %d on file [%s] (got record [%s])",
+			name, key.Pos, f.Name(), rec.Name(),
+		)
+	}
+
+	if istable && table.hdr.Index > 0 {
+		err = f.loadIndex(table)
+		if err != nil {
+			return err
 		}
 	}
 
-	if table, ok := v.(*Table); ok {
-		stream, err := rio.Open(f.f.Name())
+	return err
+}
+
+// loadIndex reads the entries index of a table, following the chain of its
+// chunks from the latest one.
+func (f *File) loadIndex(table *Table) error {
+	idxname := "hio.Index/" + table.hdr.Name
+	rec := f.f.Record(idxname)
+	rec.SetUnpack(true)
+
+	var chunks []tableIndex
+	for pos := table.hdr.Index; pos > 0; {
+		var chunk indexChunk
+		err := rec.Connect(idxname, &chunk)
+		if err != nil && err != rio.ErrBlockConnected {
+			return err
+		}
+
+		_, err = f.f.Seek(pos, 0)
+		if err != nil {
+			return err
+		}
+
+		rec, err := f.f.ReadRecord()
 		if err != nil {
 			return err
 		}
-		table.setStream(stream)
-		table.doclose = true
+
+		if rec.Name() != idxname || chunk.Prev >= pos {
+			return fmt.Errorf(
+				"hio: invalid index record [%s] at offset %d for table [%s] on file [%s]",
+				rec.Name(), pos, table.hdr.Name, f.Name(),
+			)
+		}
+		chunks = append(chunks, chunk.Index)
+		pos = chunk.Prev
 	}
 
-	return f.dict.Get(name, v)
+	table.idx = tableIndex{}
+	for i := len(chunks) - 1; i >= 0; i-- {
+		table.idx.add(chunks[i])
+	}
+	table.saved = table.idx.count()
+	return nil
+}
+
+// scanOffsets returns the positions of the first n records of the named
+// table, scanning the file from its beginning.
+func (f *File) scanOffsets(name string, n int64) ([]int64, error) {
+	stream, err := rio.Open(f.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	// first pass: register the records of the file, so all of them are
+	// unpacked, and their positions known, during the second one.
+	_, err = stream.Seek(f.begin, 0)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		_, err = stream.ReadRecord()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, rec := range stream.Records() {
+		rec.SetUnpack(true)
+	}
+
+	_, err = stream.Seek(f.begin, 0)
+	if err != nil {
+		return nil, err
+	}
+	offsets := make([]int64, 0, n)
+	for int64(len(offsets)) < n {
+		pos := stream.CurPos()
+		rec, err := stream.ReadRecord()
+		if err == io.EOF {
+			return nil, fmt.Errorf(
+				"hio: found %d entries of table [%s] on file [%s] (expected %d)",
+				len(offsets), name, f.Name(), n,
+			)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if rec.Name() == name {
+			offsets = append(offsets, pos)
+		}
+	}
+	return offsets, nil
 }
 
 func (f *File) Has(name string) bool {
-	return f.dict.Has(name)
+	return f.dict.Has(f.resolve(name))
 }
 
+// Del removes the named key, with all its cycles, or the named directory,
+// with all its content, from the file.
+// A single cycle of a value is removed by naming it, as in "calib;1": when
+// the latest cycle is removed, the previous one becomes the latest.
+// The records of the key are left on file, unreferenced, until the file is
+// compacted.
 func (f *File) Del(name string) error {
 	if f.mode != "w" {
 		return fmt.Errorf("hio: only writable files can delete keys")
 	}
 
-	err := f.dict.Del(name)
+	if p, err := cleanPath(name); err == nil && p != "" && !f.dict.Has(p) && f.isDir(p) {
+		return f.rmdir(p)
+	}
+
+	base, cycle := splitCycle(name)
+	switch {
+	case cycle == 0:
+		for _, k := range f.cycles(name) {
+			err := f.del(k)
+			if err != nil {
+				return err
+			}
+		}
+		return f.del(name)
+
+	case f.resolve(name) == base:
+		err := f.del(base)
+		if err != nil {
+			return err
+		}
+		if prev := f.cycles(base); len(prev) > 0 {
+			return f.renameKey(prev[len(prev)-1], base)
+		}
+		return nil
+	}
+
+	return f.del(name)
+}
+
+// del removes the named key from the file.
+func (f *File) del(name string) error {
+	v, err := f.dict.get(name)
+	if err != nil {
+		return err
+	}
+	err = f.dict.Del(name)
 	if err != nil {
 		return err
 	}
 	if f.tosync.has(name) {
 		f.tosync.del(name)
 	}
+	if i := f.footer.getidx(name); i >= 0 {
+		// a later cycle may take the name of the key.
+		f.footer.Keys = append(f.footer.Keys[:i], f.footer.Keys[i+1:]...)
+	}
+	if f.tables.has(name) {
+		// pending entries of the table are dropped: its records are left
+		// unreferenced on file, until Compact.
+		f.tables.del(name)
+		if table, ok := v.(*Table); ok {
+			err = table.stop()
+		}
+	}
+	delete(f.meta, name)
+	delete(f.sums, name)
 	return err
 }
 
@@ -365,16 +1142,41 @@ func (f *File) Set(name string, v Value) error {
 		return fmt.Errorf("hio: only writable files can add/modify keys")
 	}
 
+	if strings.Contains(name, ";") {
+		return fmt.Errorf("hio: invalid key name [%s] (';' is reserved for cycles)", name)
+	}
+	if f.isDir(name) {
+		return fmt.Errorf("hio: key [%s] is a directory", name)
+	}
+	if rv := reflect.ValueOf(v); !rv.IsValid() || (rv.Kind() == reflect.Ptr && rv.IsNil()) {
+		return fmt.Errorf("hio: invalid nil value for key [%s]", name)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := v.(*Table); !ok && f.dict.Has(name) && !f.tables.has(name) && !f.meta[name].Table {
+		// keep the current value as a previous cycle.
+		err := f.archive(name)
+		if err != nil {
+			return err
+		}
+	}
+
 	err := f.dict.Set(name, v)
 	if err != nil {
 		return err
 	}
+
+	m := metaOf(name, v)
 	pos := f.f.CurPos()
 	if table, ok := v.(*Table); ok {
+		m.Table = true
+		m.Type = table.hdr.Type
 		f.tables.set(name, pos)
 		hdrname := "hio.Header/" + name
 		rec := f.f.Record(hdrname)
-		err = rec.Connect(hdrname, &table.hdr)
+		err = table.hdr.connect(rec)
 		if err != nil {
 			return err
 		}
@@ -386,7 +1188,9 @@ func (f *File) Set(name string, v Value) error {
 		_ = f.f.Record(name)
 	} else {
 		f.tosync.set(name, pos)
+		delete(f.sums, name)
 	}
+	f.meta[name] = m
 	return err
 }
 
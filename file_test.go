@@ -1,8 +1,10 @@
 package hio
 
 import (
+	"fmt"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"testing"
@@ -81,6 +83,530 @@ func TestFileInspect(t *testing.T) {
 	}
 }
 
+func TestFileUpdate(t *testing.T) {
+	const fname = "testdata/file-update.hio"
+	defer os.RemoveAll(fname)
+	testFileCreateAndFill(t, fname)
+
+	err := os.Chmod(fname, 0640)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s] for update: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		if !reflect.DeepEqual(f.Keys(), g_keys) {
+			t.Fatalf("expected keys=%v. got %v.", g_keys, f.Keys())
+		}
+
+		i := int64(666)
+		err = f.Set("new-int64", &i)
+		if err != nil {
+			t.Fatalf("could not add data to file [%s]: %v", fname, err)
+		}
+
+		err = f.Del("float64")
+		if err != nil {
+			t.Fatalf("could not remove data from file [%s]: %v", fname, err)
+		}
+	}()
+
+	// the file is replaced by its copy, with the same mode.
+	fi, err := os.Stat(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0640 {
+		t.Fatalf("expected mode %v. got %v", os.FileMode(0640), mode)
+	}
+	tmps, err := filepath.Glob(fname + ".*.tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmps) != 0 {
+		t.Fatalf("expected no temporary files. got %v", tmps)
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	keys := []string{"int64", "my-struct", "new-int64"}
+	if !reflect.DeepEqual(f.Keys(), keys) {
+		t.Fatalf("expected keys=%v. got %v.", keys, f.Keys())
+	}
+
+	var s MyStruct
+	err = f.Get("my-struct", &s)
+	if err != nil {
+		t.Fatalf("could not get data [my-struct] from file: %v", err)
+	}
+	if !reflect.DeepEqual(s, g_table[2].value) {
+		t.Fatalf("expected [my-struct] data to be %v. got=%v", g_table[2].value, s)
+	}
+
+	var i int64
+	err = f.Get("new-int64", &i)
+	if err != nil {
+		t.Fatalf("could not get data [new-int64] from file: %v", err)
+	}
+	if i != 666 {
+		t.Fatalf("expected [new-int64] data to be 666. got=%v", i)
+	}
+}
+
+func TestFileOpenNewerVersion(t *testing.T) {
+	const fname = "testdata/file-newer-version.hio"
+	defer os.RemoveAll(fname)
+
+	func() {
+		version := g_version
+		g_version++
+		defer func() { g_version = version }()
+		testFileCreateAndFill(t, fname)
+	}()
+
+	_, err := Open(fname)
+	if err == nil {
+		t.Fatalf("expected an error opening file [%s] of version %d", fname, g_version+1)
+	}
+
+	_, err = OpenFile(fname, os.O_RDWR)
+	if err == nil {
+		t.Fatalf("expected an error opening file [%s] of version %d for update", fname, g_version+1)
+	}
+
+	err = Recover(fname)
+	if err == nil {
+		t.Fatalf("expected an error recovering file [%s] of version %d", fname, g_version+1)
+	}
+}
+
+func TestFileUpdateFailure(t *testing.T) {
+	const dir = "testdata/file-update-failure"
+	const fname = dir + "/file.hio"
+	err := os.Mkdir(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	testFileCreateAndFill(t, fname)
+	size := fileSize(t, fname)
+
+	// the copy of the file can not be created next to it.
+	err = os.Chmod(dir, 0555)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir, 0755)
+	if w, err := os.CreateTemp(dir, "probe"); err == nil {
+		w.Close()
+		os.Remove(w.Name())
+		t.Skipf("directory [%s] is still writable", dir)
+	}
+
+	_, err = OpenFile(fname, os.O_RDWR)
+	if err == nil {
+		t.Fatalf("expected an error opening file [%s] for update", fname)
+	}
+
+	if n := fileSize(t, fname); n != size {
+		t.Fatalf("file [%s] modified: size=%d, expected %d", fname, n, size)
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	if !reflect.DeepEqual(f.Keys(), g_keys) {
+		t.Fatalf("expected keys=%v. got %v.", g_keys, f.Keys())
+	}
+}
+
+func TestFileGetRandomAccess(t *testing.T) {
+	const fname = "testdata/file-random-access.hio"
+	const nkeys = 500
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		for i := 0; i < nkeys; i++ {
+			v := int64(i)
+			err = f.Set(fmt.Sprintf("key-%03d", i), &v)
+			if err != nil {
+				t.Fatalf("could not put data [%d] into file: %v", i, err)
+			}
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	for _, i := range rand.Perm(nkeys) {
+		key := fmt.Sprintf("key-%03d", i)
+		var v int64
+		err = f.Get(key, &v)
+		if err != nil {
+			t.Fatalf("could not get data [%s] from file: %v", key, err)
+		}
+		if v != int64(i) {
+			t.Fatalf("expected [%s] data to be %d. got=%d", key, i, v)
+		}
+	}
+}
+
+func TestFileCheckpoint(t *testing.T) {
+	const fname = "testdata/file-checkpoint.hio"
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+
+	for _, test := range []struct {
+		name    string
+		every   int64 // number of entries between automatic checkpoints
+		entries int64 // number of entries on file after a crash
+	}{
+		{
+			name:    "explicit",
+			every:   0,
+			entries: 10,
+		},
+		{
+			name:    "automatic",
+			every:   4,
+			entries: 12,
+		},
+	} {
+		func() {
+			f, err := Create(fname)
+			if err != nil {
+				t.Fatalf("%s: could not create file [%s]: %v", test.name, fname, err)
+			}
+			f.SetCheckpoint(test.every, 0)
+
+			table, err := NewTable(f, tname)
+			if err != nil {
+				t.Fatalf("%s: could not create table [%s]: %v", test.name, tname, err)
+			}
+
+			v := int64(42)
+			err = f.Set("int64", &v)
+			if err != nil {
+				t.Fatalf("%s: could not put data into file: %v", test.name, err)
+			}
+
+			for i := 0; i < 15; i++ {
+				if i == 10 && test.every == 0 {
+					err = f.Checkpoint()
+					if err != nil {
+						t.Fatalf("%s: could not checkpoint file: %v", test.name, err)
+					}
+				}
+				data := int64(i)
+				err = table.Write(&data)
+				if err != nil {
+					t.Fatalf("%s: could not write to table [i=%d]: %v", test.name, i, err)
+				}
+			}
+
+			// simulate a crash: the final footer is never written.
+			err = f.f.Close()
+			if err != nil {
+				t.Fatalf("%s: could not close stream: %v", test.name, err)
+			}
+		}()
+
+		func() {
+			f, err := Open(fname)
+			if err != nil {
+				t.Fatalf("%s: could not open file [%s]: %v", test.name, fname, err)
+			}
+			defer f.Close()
+
+			keys := []string{"int64", tname}
+			if !reflect.DeepEqual(f.Keys(), keys) {
+				t.Fatalf("%s: expected keys=%v. got %v.", test.name, keys, f.Keys())
+			}
+
+			var v int64
+			err = f.Get("int64", &v)
+			if err != nil {
+				t.Fatalf("%s: could not get data [int64] from file: %v", test.name, err)
+			}
+			if v != 42 {
+				t.Fatalf("%s: expected [int64] data to be 42. got=%v", test.name, v)
+			}
+
+			var table Table
+			err = f.Get(tname, &table)
+			if err != nil {
+				t.Fatalf("%s: could not retrieve table [%s]: %v", test.name, tname, err)
+			}
+			defer table.Close()
+
+			if table.Entries() != test.entries {
+				t.Fatalf("%s: expected [%d] entries. got [%d]", test.name, test.entries, table.Entries())
+			}
+		}()
+	}
+}
+
+func TestFileCheckpointSize(t *testing.T) {
+	const fname = "testdata/file-checkpoint-size.hio"
+	const tname = "my-table"
+	const nentries = 1000
+	defer os.RemoveAll(fname)
+
+	size := func(every int64) int64 {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		f.SetCheckpoint(every, 0)
+
+		table, err := NewTable(f, tname)
+		if err != nil {
+			t.Fatalf("could not create table [%s]: %v", tname, err)
+		}
+
+		v := newMyStruct(42)
+		err = f.Set("my-struct", &v)
+		if err != nil {
+			t.Fatalf("could not put data into file: %v", err)
+		}
+
+		for i := 0; i < nentries; i++ {
+			data := int64(i)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write to table [i=%d]: %v", i, err)
+			}
+		}
+		// modified after the last checkpoint.
+		v.Int = 666
+
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("could not close file [%s]: %v", fname, err)
+		}
+
+		r, err := Open(fname)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer r.Close()
+
+		var got MyStruct
+		err = r.Get("my-struct", &got)
+		if err != nil {
+			t.Fatalf("could not get data [my-struct] from file: %v", err)
+		}
+		if !reflect.DeepEqual(got, v) {
+			t.Fatalf("expected [my-struct] data to be %v. got=%v", v, got)
+		}
+
+		var table2 Table
+		err = r.Get(tname, &table2)
+		if err != nil {
+			t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+		}
+		defer table2.Close()
+		for _, i := range []int64{0, nentries / 2, nentries - 1} {
+			var data int64
+			err = table2.ReadAt(i, &data)
+			if err != nil {
+				t.Fatalf("could not read entry [%d]: %v", i, err)
+			}
+			if data != i {
+				t.Fatalf("expected entry [%d] to be %d. got %d", i, i, data)
+			}
+		}
+
+		return fileSize(t, fname)
+	}
+
+	ref := size(0)
+	// 100 checkpoints: the index is written in chunks, and the unchanged
+	// value is written once.
+	if n := size(10); n > 2*ref {
+		t.Fatalf("checkpointed file too large: %d bytes (%d bytes without checkpoints)", n, ref)
+	}
+}
+
+func TestFileMixedContent(t *testing.T) {
+	const fname = "testdata/file-mixed.hio"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+
+	newH1D := func(n int) *hbook.H1D {
+		h := hbook.NewH1D(100, 0, 100)
+		h.Annotation()["name"] = fmt.Sprintf("histo-%d", n)
+		for i := 0; i < nentries*n; i++ {
+			h.Fill(float64(i), 1)
+		}
+		return h
+	}
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		t1, err := NewTable(f, "table-1")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+
+		err = f.Set("histo-1", newH1D(1))
+		if err != nil {
+			t.Fatalf("could not save histo: %v", err)
+		}
+
+		t2, err := NewTable(f, "table-2")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+
+		for i := 0; i < nentries; i++ {
+			v1 := int64(i)
+			err = t1.Write(&v1)
+			if err != nil {
+				t.Fatalf("could not write to table [i=%d]: %v", i, err)
+			}
+			v2 := newMyStruct(int64(i))
+			err = t2.Write(&v2)
+			if err != nil {
+				t.Fatalf("could not write to table [i=%d]: %v", i, err)
+			}
+		}
+
+		s := newMyStruct(42)
+		err = f.Set("my-struct", &s)
+		if err != nil {
+			t.Fatalf("could not save struct: %v", err)
+		}
+
+		err = f.Set("histo-2", newH1D(2))
+		if err != nil {
+			t.Fatalf("could not save histo: %v", err)
+		}
+	}()
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s] for update: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		err = f.Set("histo-3", newH1D(3))
+		if err != nil {
+			t.Fatalf("could not save histo: %v", err)
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	keys := []string{"histo-1", "histo-2", "histo-3", "my-struct", "table-1", "table-2"}
+	if !reflect.DeepEqual(f.Keys(), keys) {
+		t.Fatalf("expected keys=%v. got %v.", keys, f.Keys())
+	}
+
+	var t2 Table
+	err = f.Get("table-2", &t2)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer t2.Close()
+
+	for _, n := range []int{3, 1, 2} {
+		var h hbook.H1D
+		name := fmt.Sprintf("histo-%d", n)
+		err = f.Get(name, &h)
+		if err != nil {
+			t.Fatalf("could not retrieve histo [%s]: %v", name, err)
+		}
+		if !reflect.DeepEqual(&h, newH1D(n)) {
+			t.Fatalf("invalid histo [%s]:\nref=%v\nnew=%v\n", name, newH1D(n), &h)
+		}
+
+		var v MyStruct
+		err = t2.Read(&v)
+		if err != nil {
+			t.Fatalf("could not read table: %v", err)
+		}
+	}
+
+	var s MyStruct
+	err = f.Get("my-struct", &s)
+	if err != nil {
+		t.Fatalf("could not retrieve struct: %v", err)
+	}
+	if !reflect.DeepEqual(s, newMyStruct(42)) {
+		t.Fatalf("invalid struct:\nref=%v\nnew=%v\n", newMyStruct(42), s)
+	}
+
+	var t1 Table
+	err = f.Get("table-1", &t1)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer t1.Close()
+
+	for i := 0; i < nentries; i++ {
+		var v int64
+		err = t1.Read(&v)
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		if v != int64(i) {
+			t.Fatalf("expected entry [%d] to be %d. got %d", i, i, v)
+		}
+	}
+}
+
 func testFileOpen(t *testing.T, fname string) {
 	f, err := Open(fname)
 	if err != nil {
@@ -1,6 +1,8 @@
 package hio
 
 import (
+	"fmt"
+
 	"github.com/go-hep/rio"
 )
 
@@ -14,25 +16,52 @@ type fileEntry struct {
 	Len  int64
 }
 
-func newFileFooterFrom(stream *rio.Stream) (FileFooter, error) {
+func (ftr FileFooter) getidx(name string) int {
+	for i, key := range ftr.Keys {
+		if key.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// newFileFooterFrom reads the footer, the description of its keys and the
+// directories of the file from stream.
+func newFileFooterFrom(stream *rio.Stream) (FileFooter, []keyMeta, []string, error) {
 	var err error
 	ftr := FileFooter{
 		Keys: make([]fileEntry, 0),
 	}
+	var meta []keyMeta
+	var dirs []string
 
 	rec := stream.Record("hio.FileFooter")
 	rec.SetUnpack(true)
 	err = rec.Connect("hio.FileFooter", &ftr)
 	if err != nil {
-		return ftr, err
+		return ftr, meta, dirs, err
+	}
+
+	// absent from files written by older versions.
+	err = rec.Connect("hio.KeyMeta", &meta)
+	if err != nil {
+		return ftr, meta, dirs, err
+	}
+	err = rec.Connect("hio.Dirs", &dirs)
+	if err != nil {
+		return ftr, meta, dirs, err
 	}
 
 	rec, err = stream.ReadRecord()
 	if err != nil {
-		return ftr, err
+		return ftr, meta, dirs, err
+	}
+
+	if rec.Name() != "hio.FileFooter" {
+		return ftr, meta, dirs, fmt.Errorf("hio: invalid footer record [%s]", rec.Name())
 	}
 
-	return ftr, err
+	return ftr, meta, dirs, err
 }
 
 // EOF
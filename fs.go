@@ -0,0 +1,400 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+	"time"
+)
+
+// FS is a read-only view of a File as a file system, implementing fs.FS,
+// fs.ReadDirFS and fs.StatFS.
+//
+// Each key of the file is a regular file whose content is the record of the
+// value as stored on file, still encoded and compressed. The content of a
+// table is its header record followed by the records of its entries (rows,
+// clusters of columns or baskets) in index order. Directories of the file
+// are directories of the file system. Previous cycles of values are not
+// listed.
+//
+// The fs.FileInfo of a key reports the size of its content and the time it
+// was last written; its Sys method returns the KeyInfo of the key. The size
+// of a table is only computed, reading the headers of its records, when
+// asked for.
+//
+// Contents are not loaded in memory: they are read from file as keys open
+// from the FS are read, and these implement io.Seeker and io.ReaderAt.
+// An FS may be used by concurrent goroutines (as by http.FileServer), as
+// long as the file is not used otherwise meanwhile.
+//
+// Only keys written to file are visible: for files open for writing, keys
+// set since the last Checkpoint are left out, and the content of keys can
+// not be read.
+type FS struct {
+	f *File
+}
+
+// FS returns a file system view of the file.
+func (f *File) FS() *FS {
+	return &FS{f: f}
+}
+
+// Open opens the named key or directory.
+func (fsys *FS) Open(name string) (fs.File, error) {
+	fi, err := fsys.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &fsDir{fi: fi, entries: entries}, nil
+	}
+
+	content, err := fsys.content(fi.info)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	fi.once.Do(func() { fi.size = content.size })
+	return &fsFile{fsContent: content, fi: fi}, nil
+}
+
+// Stat returns the description of the named key or directory.
+func (fsys *FS) Stat(name string) (fs.FileInfo, error) {
+	fi, err := fsys.stat("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fi, nil
+}
+
+// ReadDir returns the entries of the named directory, sorted by name.
+func (fsys *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fi, err := fsys.stat("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, &fs.PathError{
+			Op:   "readdir",
+			Path: name,
+			Err:  fmt.Errorf("hio: key [%s] is not a directory", name),
+		}
+	}
+
+	dir := &Dir{f: fsys.f, path: fi.path}
+	var entries []fs.DirEntry
+	for _, k := range dir.Keys() {
+		fi, ok := fsys.lookup(dir.key(k))
+		if !ok {
+			// not written to file yet.
+			continue
+		}
+		entries = append(entries, fs.FileInfoToDirEntry(fi))
+	}
+	return entries, nil
+}
+
+// stat returns the description of the named key or directory, or an
+// fs.PathError for the operation op.
+func (fsys *FS) stat(op, name string) (*fsInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	fi, ok := fsys.lookup(name)
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return fi, nil
+}
+
+// lookup returns the description of the named key or directory.
+// Keys whose names are not valid fs paths are left out.
+func (fsys *FS) lookup(name string) (*fsInfo, bool) {
+	f := fsys.f
+	if name == "." {
+		return &fsInfo{name: ".", dir: true}, true
+	}
+	if !fs.ValidPath(name) {
+		return nil, false
+	}
+
+	if _, cycle := splitCycle(name); cycle == 0 && f.dict.Has(name) {
+		if f.footer.getidx(name) < 0 {
+			return nil, false
+		}
+		info := f.keyInfo(name)
+		return &fsInfo{fsys: fsys, name: path.Base(name), path: name, info: info}, true
+	}
+
+	if f.isDir(name) {
+		return &fsInfo{name: path.Base(name), path: name, dir: true}, true
+	}
+	return nil, false
+}
+
+// content returns a reader of the content of the described key: its
+// record, or the records of a table.
+func (fsys *FS) content(info KeyInfo) (*fsContent, error) {
+	f := fsys.f
+	if f.mode == "w" {
+		return nil, fmt.Errorf("hio: can not read keys of file [%s] open for writing", f.Name())
+	}
+
+	recs, err := fsys.records(info)
+	if err != nil {
+		return nil, err
+	}
+
+	content := &fsContent{parts: make([]*io.SectionReader, len(recs))}
+	for i, rec := range recs {
+		sr, err := f.rawSection(rec.name, rec.pos)
+		if err != nil {
+			return nil, err
+		}
+		content.parts[i] = sr
+		content.size += sr.Size()
+	}
+	return content, nil
+}
+
+// fsRecord locates a record of the content of a key.
+type fsRecord struct {
+	name string
+	pos  int64
+}
+
+// records returns the records making the content of the described key: the
+// record of a value, or the header record of a table followed by the
+// records of its entries, in index order.
+func (fsys *FS) records(info KeyInfo) ([]fsRecord, error) {
+	f := fsys.f
+	if !info.Table {
+		recname, _ := splitCycle(info.Name)
+		return []fsRecord{{name: recname, pos: info.Pos}}, nil
+	}
+
+	recs := []fsRecord{{name: "hio.Header/" + info.Name, pos: info.Pos}}
+
+	// the header and the index of the table are read from the stream of
+	// the file, shared by all the files open from the FS.
+	var table Table
+	f.mu.Lock()
+	err := f.load(info.Name, &table)
+	f.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case table.hdr.Cluster > 0:
+		for _, cluster := range table.idx.Clusters {
+			for j, col := range table.idx.Columns {
+				recs = append(recs, fsRecord{name: colrecname(table.hdr.Name, col), pos: cluster.Offsets[j]})
+			}
+		}
+
+	case table.basketed():
+		for _, basket := range table.idx.Baskets {
+			recs = append(recs, fsRecord{name: bktrecname(table.hdr.Name), pos: basket.Offset})
+		}
+
+	default:
+		offsets := table.idx.Offsets
+		if table.hdr.Version == 0 {
+			// table written without an index.
+			offsets, err = f.scanOffsets(table.hdr.Name, table.hdr.Entries)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for _, off := range offsets {
+			recs = append(recs, fsRecord{name: table.hdr.Name, pos: off})
+		}
+	}
+	return recs, nil
+}
+
+// fsInfo describes a key or a directory of an FS.
+type fsInfo struct {
+	fsys *FS
+	name string
+	path string // path of the key or directory in the file
+	dir  bool
+	info KeyInfo
+
+	once sync.Once
+	size int64 // size of the content of a table
+}
+
+func (fi *fsInfo) Name() string {
+	return fi.name
+}
+
+func (fi *fsInfo) Size() int64 {
+	if !fi.info.Table {
+		return fi.info.Len
+	}
+	fi.once.Do(func() {
+		content, err := fi.fsys.content(fi.info)
+		if err != nil {
+			// best estimate for tables which can not be read.
+			fi.size = fi.info.Size
+			return
+		}
+		fi.size = content.size
+	})
+	return fi.size
+}
+
+func (fi *fsInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+func (fi *fsInfo) ModTime() time.Time {
+	return fi.info.Time
+}
+
+func (fi *fsInfo) IsDir() bool {
+	return fi.dir
+}
+
+// Sys returns the KeyInfo of a key, and nil for directories.
+func (fi *fsInfo) Sys() interface{} {
+	if fi.dir {
+		return nil
+	}
+	return fi.info
+}
+
+// fsFile is a key of an FS, open for reading.
+type fsFile struct {
+	*fsContent
+	fi *fsInfo
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.fi, nil
+}
+
+func (f *fsFile) Close() error {
+	return nil
+}
+
+// fsContent reads the records making the content of a key, directly from
+// file, as a single sequence of bytes.
+type fsContent struct {
+	parts []*io.SectionReader
+	size  int64
+	off   int64
+}
+
+func (c *fsContent) Read(p []byte) (int, error) {
+	n, err := c.ReadAt(p, c.off)
+	c.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (c *fsContent) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("hio: negative offset")
+	}
+	if off >= c.size {
+		return 0, io.EOF
+	}
+
+	n := 0
+	for _, part := range c.parts {
+		if len(p) == 0 {
+			break
+		}
+		if off >= part.Size() {
+			off -= part.Size()
+			continue
+		}
+		m, err := part.ReadAt(p, off)
+		n += m
+		p = p[m:]
+		off = 0
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+	}
+	if len(p) > 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (c *fsContent) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += c.off
+	case io.SeekEnd:
+		offset += c.size
+	default:
+		return 0, fmt.Errorf("hio: invalid whence [%d]", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("hio: negative position")
+	}
+	c.off = offset
+	return offset, nil
+}
+
+// fsDir is a directory of an FS, open for reading.
+type fsDir struct {
+	fi      *fsInfo
+	entries []fs.DirEntry
+	off     int
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.fi, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.fi.path, Err: fmt.Errorf("hio: is a directory")}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.off:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	d.off += len(entries)
+	return entries, nil
+}
+
+// check interfaces
+var (
+	_ fs.FS          = (*FS)(nil)
+	_ fs.ReadDirFS   = (*FS)(nil)
+	_ fs.StatFS      = (*FS)(nil)
+	_ fs.ReadDirFile = (*fsDir)(nil)
+	_ io.ReadSeeker  = (*fsFile)(nil)
+	_ io.ReaderAt    = (*fsFile)(nil)
+)
+
+// EOF
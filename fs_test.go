@@ -0,0 +1,238 @@
+package hio
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// testFSCreate creates a file holding values, cycles, tables of all the
+// layouts and directories, for the tests of FS.
+func testFSCreate(t *testing.T, fname string) {
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+	defer func() {
+		err = f.Close()
+		if err != nil {
+			t.Fatalf("could not close file [%s]: %v", fname, err)
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		calib := int64(i)
+		err = f.Set("calib", &calib)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+	}
+
+	muons, err := f.Mkdir("run1/muons")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+	data := newMyStruct(42)
+	err = muons.Set("data", &data)
+	if err != nil {
+		t.Fatalf("could not set value: %v", err)
+	}
+
+	table, err := NewTable(f, "run1/events")
+	if err != nil {
+		t.Fatalf("could not create table: %v", err)
+	}
+	cols, err := NewTable(f, "run1/cols", WithColumns(4))
+	if err != nil {
+		t.Fatalf("could not create table: %v", err)
+	}
+	bkts, err := NewTable(f, "run1/bkts", WithBaskets(4, 0))
+	if err != nil {
+		t.Fatalf("could not create table: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		data := newTableData(i)
+		err = table.Write(&data)
+		if err != nil {
+			t.Fatalf("could not write entry [%d]: %v", i, err)
+		}
+		col := newColData(i)
+		err = cols.Write(&col)
+		if err != nil {
+			t.Fatalf("could not write entry [%d]: %v", i, err)
+		}
+		err = bkts.Write(&data)
+		if err != nil {
+			t.Fatalf("could not write entry [%d]: %v", i, err)
+		}
+	}
+
+	_, err = f.Mkdir("run2")
+	if err != nil {
+		t.Fatalf("could not create directory: %v", err)
+	}
+}
+
+func TestFileFS(t *testing.T) {
+	const fname = "testdata/file-fs.hio"
+	defer os.RemoveAll(fname)
+
+	testFSCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	fsys := f.FS()
+	err = fstest.TestFS(fsys, "calib", "run1/muons/data", "run1/events", "run1/cols", "run1/bkts", "run2")
+	if err != nil {
+		t.Fatalf("invalid file system: %v", err)
+	}
+
+	var names []string
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		names = append(names, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not walk file system: %v", err)
+	}
+	want := []string{".", "calib", "run1", "run1/bkts", "run1/cols", "run1/events", "run1/muons", "run1/muons/data", "run2"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("expected paths %v. got %v", want, names)
+	}
+
+	for _, name := range []string{"calib", "run1/events", "run1/cols", "run1/bkts"} {
+		fi, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatalf("could not stat [%s]: %v", name, err)
+		}
+		info, ok := fi.Sys().(KeyInfo)
+		if !ok {
+			t.Fatalf("%s: expected a KeyInfo. got %T", name, fi.Sys())
+		}
+		if info.Name != name {
+			t.Fatalf("%s: invalid file info %+v", name, info)
+		}
+		if (info.Table && fi.Size() <= info.Len) || (!info.Table && fi.Size() != info.Len) {
+			t.Fatalf("%s: invalid size [%d] (record of [%d] bytes)", name, fi.Size(), info.Len)
+		}
+
+		buf, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatalf("could not read [%s]: %v", name, err)
+		}
+		if int64(len(buf)) != fi.Size() {
+			t.Fatalf("%s: expected [%d] bytes. got [%d]", name, fi.Size(), len(buf))
+		}
+	}
+
+	// the content of a table is its header and entries, in index order.
+	func() {
+		const name = "run1/events"
+		var table Table
+		err = f.Get(name, &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table: %v", err)
+		}
+		defer table.Close()
+
+		fi, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatalf("could not stat [%s]: %v", name, err)
+		}
+		info := fi.Sys().(KeyInfo)
+		want, err := f.rawRecord("hio.Header/"+name, info.Pos)
+		if err != nil {
+			t.Fatalf("could not read header: %v", err)
+		}
+		if n := len(table.idx.Offsets); n != 10 {
+			t.Fatalf("expected [10] entries in index. got [%d]", n)
+		}
+		for _, off := range table.idx.Offsets {
+			rec, err := f.rawRecord(name, off)
+			if err != nil {
+				t.Fatalf("could not read entry at [%d]: %v", off, err)
+			}
+			want = append(want, rec...)
+		}
+
+		got, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatalf("could not read [%s]: %v", name, err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s: invalid content (got %d bytes, want %d)", name, len(got), len(want))
+		}
+	}()
+
+	for _, name := range []string{"calib;1", "run3", "/calib", "run1/"} {
+		_, err = fsys.Open(name)
+		if err == nil {
+			t.Fatalf("expected an error opening [%s]", name)
+		}
+	}
+}
+
+func TestFileFSConcurrent(t *testing.T) {
+	const fname = "testdata/file-fs-concurrent.hio"
+	const nworkers = 8
+	defer os.RemoveAll(fname)
+
+	testFSCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	names := []string{"calib", "run1/muons/data", "run1/events", "run1/cols", "run1/bkts"}
+	want := make(map[string][]byte, len(names))
+	for _, name := range names {
+		want[name], err = fs.ReadFile(f.FS(), name)
+		if err != nil {
+			t.Fatalf("could not read [%s]: %v", name, err)
+		}
+	}
+
+	fsys := f.FS()
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < nworkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			for _, name := range names {
+				fi, err := fs.Stat(fsys, name)
+				if err != nil {
+					t.Errorf("could not stat [%s]: %v", name, err)
+					return
+				}
+				buf, err := fs.ReadFile(fsys, name)
+				if err != nil {
+					t.Errorf("could not read [%s]: %v", name, err)
+					return
+				}
+				if !bytes.Equal(buf, want[name]) || fi.Size() != int64(len(buf)) {
+					t.Errorf("%s: invalid content (got %d bytes, want %d)", name, len(buf), len(want[name]))
+					return
+				}
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+}
+
+// EOF
@@ -0,0 +1,47 @@
+package hio
+
+import (
+	"fmt"
+
+	"github.com/go-hep/hbook"
+)
+
+// histograms of hbook are merged by Merge without further registration.
+func init() {
+	registerMerge(new(hbook.H1D), mergeH1D, true)
+}
+
+// mergeH1D adds the bins of the histogram src to dst, which must have the
+// same binning.
+//
+// Each bin of src is filled into dst at its abscissa, with its content as
+// weight: bin contents are summed exactly. The public API of hbook does not
+// give access to the other statistics of bins, nor to the under/overflows:
+// the number of entries and the moments of dst are those of the filled bin
+// contents, and the under/overflows of src are left out.
+func mergeH1D(dst, src Value) error {
+	hsrc := src.(*hbook.H1D)
+	hdst := dst.(*hbook.H1D)
+
+	if hdst.Len() != hsrc.Len() {
+		return fmt.Errorf("hio: histograms with different binnings: %d and %d bins", hdst.Len(), hsrc.Len())
+	}
+	for i := 0; i < hsrc.Len(); i++ {
+		xdst, _ := hdst.XY(i)
+		xsrc, _ := hsrc.XY(i)
+		if xdst != xsrc {
+			return fmt.Errorf("hio: histograms with different edges for bin [%d]: %v and %v", i, xdst, xsrc)
+		}
+	}
+
+	for i := 0; i < hsrc.Len(); i++ {
+		x, y := hsrc.XY(i)
+		if y == 0 {
+			continue
+		}
+		hdst.Fill(x, y)
+	}
+	return nil
+}
+
+// EOF
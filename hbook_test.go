@@ -0,0 +1,82 @@
+package hio
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-hep/hbook"
+)
+
+func TestMergeH1D(t *testing.T) {
+	const dst = "testdata/merge-h1d-out.hio"
+	const nfiles = 3
+	const nentries = 100
+	defer os.RemoveAll(dst)
+
+	// weights are chosen to be summed exactly in any order.
+	fill := func(h *hbook.H1D, i int) {
+		h.Fill(float64(i%10)+0.5, float64(1+i%3))
+	}
+
+	href := hbook.NewH1D(10, 0, 10)
+
+	srcs := make([]string, nfiles)
+	for k := range srcs {
+		srcs[k] = fmt.Sprintf("testdata/merge-h1d-%d.hio", k)
+		defer os.RemoveAll(srcs[k])
+
+		func() {
+			f, err := Create(srcs[k])
+			if err != nil {
+				t.Fatalf("could not create file [%s]: %v", srcs[k], err)
+			}
+			defer func() {
+				err = f.Close()
+				if err != nil {
+					t.Fatalf("could not close file [%s]: %v", srcs[k], err)
+				}
+			}()
+
+			h := hbook.NewH1D(10, 0, 10)
+			for i := k * nentries; i < (k+1)*nentries; i++ {
+				fill(h, i)
+				fill(href, i)
+			}
+			err = f.Set("h1", h)
+			if err != nil {
+				t.Fatalf("could not set histo: %v", err)
+			}
+		}()
+	}
+
+	err := Merge(dst, srcs...)
+	if err != nil {
+		t.Fatalf("could not merge files: %v", err)
+	}
+
+	f, err := Open(dst)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", dst, err)
+	}
+	defer f.Close()
+
+	var h hbook.H1D
+	err = f.Get("h1", &h)
+	if err != nil {
+		t.Fatalf("could not get histo: %v", err)
+	}
+
+	if h.Len() != href.Len() {
+		t.Fatalf("expected [%d] bins. got [%d]", href.Len(), h.Len())
+	}
+	for i := 0; i < href.Len(); i++ {
+		x, y := h.XY(i)
+		xref, yref := href.XY(i)
+		if x != xref || y != yref {
+			t.Fatalf("bin [%d]: expected (%v, %v). got (%v, %v)", i, xref, yref, x, y)
+		}
+	}
+}
+
+// EOF
@@ -1,6 +1,8 @@
 package hio
 
 import (
+	"fmt"
+
 	"github.com/go-hep/rio"
 )
 
@@ -28,6 +30,18 @@ func newFileHeaderFrom(stream *rio.Stream) (FileHeader, error) {
 	return hdr, err
 }
 
+// check returns an error if the file was written by a newer version of
+// hio, whose records can not be decoded.
+func (hdr FileHeader) check(fname string) error {
+	if hdr.Version > g_version {
+		return fmt.Errorf(
+			"hio: file [%s] has version %d, newer than the supported version %d",
+			fname, hdr.Version, g_version,
+		)
+	}
+	return nil
+}
+
 func newFileHeader(stream *rio.Stream) (FileHeader, error) {
 	var err error
 	hdr := FileHeader{}
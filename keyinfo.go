@@ -0,0 +1,146 @@
+package hio
+
+import (
+	"reflect"
+	"sort"
+	"time"
+)
+
+// KeyInfo describes a key of a file, as recorded in its footer.
+type KeyInfo struct {
+	Name    string
+	Type    string    // name of the Go type of the value, or of the entries of a table
+	Table   bool      // whether the key is a Table
+	Pos     int64     // position on file of the value record, or of the table header
+	Len     int64     // length of the value record, or of the table header
+	Size    int64     // bytes used on file, including table entries
+	RawSize int64     // estimated size of the data before compression
+	Entries int64     // number of entries of a table
+	Codec   string    // codec compressing the entries of a table, empty if compressed by rio
+	Cycle   int64     // cycle number of a value, from 1
+	Time    time.Time // time the value or the table was last written
+	Fields  []Field   // fields of struct values or entries, if recorded
+}
+
+// Field describes a field of struct values, or of the entries of a table,
+// so they can be decoded field by field without their Go type.
+// Fields are only recorded for structs with only exported fields and no
+// custom encoding.
+type Field struct {
+	Name string
+	Type string // name of the Go type of the field
+}
+
+// keyMeta holds the description of a key written in the footer, along with
+// the list of keys.
+type keyMeta struct {
+	Name    string
+	Type    string
+	Table   bool
+	Bytes   int64 // bytes used on file by table entries
+	RawSize int64
+	Entries int64
+	Time    int64  // unix time in nanoseconds
+	Codec   string // codec of a table
+	Fields  []Field
+}
+
+// KeyInfos returns the description of the keys of the file, sorted by name.
+//
+// The description is read from the footer, without decoding values.
+// For files opened for writing, it reflects the last footer written by
+// Checkpoint, or the footer the file was opened with, less the keys deleted
+// since.
+// Files written by older versions of hio only record the name, position
+// and length of keys.
+func (f *File) KeyInfos() []KeyInfo {
+	infos := make([]KeyInfo, 0, len(f.footer.Keys))
+	for _, key := range f.footer.Keys {
+		infos = append(infos, f.keyInfo(key.Name))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// keyInfo returns the description of the named key.
+func (f *File) keyInfo(name string) KeyInfo {
+	info := KeyInfo{
+		Name:  name,
+		Cycle: f.cycle(name),
+	}
+	if _, cycle := splitCycle(name); cycle > 0 {
+		info.Cycle = cycle
+	}
+	if i := f.footer.getidx(name); i >= 0 {
+		info.Pos = f.footer.Keys[i].Pos
+		info.Len = f.footer.Keys[i].Len
+		info.Size = info.Len
+	}
+	if m, ok := f.meta[name]; ok {
+		info.Type = m.Type
+		info.Table = m.Table
+		info.Size += m.Bytes
+		info.RawSize = m.RawSize
+		info.Entries = m.Entries
+		info.Codec = m.Codec
+		info.Fields = m.Fields
+		if m.Time != 0 {
+			info.Time = time.Unix(0, m.Time)
+		}
+	}
+	return info
+}
+
+// metaOf returns the description of the value v set under name.
+func metaOf(name string, v Value) keyMeta {
+	m := keyMeta{
+		Name: name,
+		Time: time.Now().UnixNano(),
+	}
+	rt := reflect.TypeOf(v)
+	if rt == nil {
+		// no type recorded.
+		return m
+	}
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	m.Type = TypeName(rt)
+	m.Fields = fieldsOf(rt)
+	return m
+}
+
+// fieldsOf returns the fields of the struct type rt, or nil if rt is not a
+// struct with only exported fields and no custom encoding.
+func fieldsOf(rt reflect.Type) []Field {
+	if rt.Kind() != reflect.Struct {
+		return nil
+	}
+	if _, ok := reflect.PtrTo(rt).MethodByName("MarshalBinary"); ok {
+		return nil
+	}
+	fields := make([]Field, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		ft := rt.Field(i)
+		if ft.PkgPath != "" || ft.Anonymous {
+			return nil
+		}
+		fields = append(fields, Field{Name: ft.Name, Type: TypeName(ft.Type)})
+	}
+	return fields
+}
+
+// metas returns the descriptions of the keys of the footer.
+func (f *File) metas() []keyMeta {
+	metas := make([]keyMeta, 0, len(f.footer.Keys))
+	for _, key := range f.footer.Keys {
+		if m, ok := f.meta[key.Name]; ok {
+			metas = append(metas, m)
+		}
+	}
+	return metas
+}
+
+// EOF
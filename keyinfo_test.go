@@ -0,0 +1,212 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-hep/hbook"
+)
+
+func TestFileKeyInfos(t *testing.T) {
+	const fname = "testdata/file-keyinfos.hio"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+
+	start := time.Now()
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		h := hbook.NewH1D(10, 0, 10)
+		err = f.Set("histo", h)
+		if err != nil {
+			t.Fatalf("could not save histo: %v", err)
+		}
+
+		table, err := NewTable(f, "table")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		typed, err := NewTypedTable[MyStruct](f, "typed")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		for i := 0; i < nentries; i++ {
+			data := newTableData(i)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+			v := newMyStruct(int64(i))
+			err = typed.Write(&v)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	infos := f.KeyInfos()
+	if len(infos) != 3 {
+		t.Fatalf("expected [3] keys. got %+v", infos)
+	}
+
+	for i, want := range []KeyInfo{
+		{Name: "histo", Type: "github.com/go-hep/hbook.H1D"},
+		{Name: "table", Type: "github.com/go-hep/hio.tableData", Table: true, Entries: nentries},
+		{Name: "typed", Type: "github.com/go-hep/hio.MyStruct", Table: true, Entries: nentries},
+	} {
+		info := infos[i]
+		if info.Name != want.Name || info.Type != want.Type || info.Table != want.Table || info.Entries != want.Entries {
+			t.Fatalf("invalid key info:\ngot= %+v\nwant=%+v", info, want)
+		}
+
+		key := f.footer.Keys[f.footer.getidx(info.Name)]
+		if info.Pos != key.Pos || info.Len != key.Len {
+			t.Fatalf("key [%s]: invalid position: got (%d, %d). want (%d, %d)", info.Name, info.Pos, info.Len, key.Pos, key.Len)
+		}
+		if info.Table && info.Size <= info.Len {
+			t.Fatalf("key [%s]: table size does not include entries: %+v", info.Name, info)
+		}
+		if info.Size < info.Len || info.RawSize <= 0 {
+			t.Fatalf("key [%s]: invalid sizes: %+v", info.Name, info)
+		}
+		if info.Time.Before(start.Add(-time.Second)) || info.Time.After(time.Now()) {
+			t.Fatalf("key [%s]: invalid time: %v", info.Name, info.Time)
+		}
+	}
+
+	// histograms have a custom encoding.
+	if fields := infos[0].Fields; len(fields) != 0 {
+		t.Fatalf("key [histo]: expected no fields. got %+v", fields)
+	}
+	fields := []Field{
+		{Name: "Ints", Type: "[]int64"},
+		{Name: "Floats", Type: "[]float64"},
+		{Name: "Strings", Type: "[]string"},
+	}
+	if !reflect.DeepEqual(infos[1].Fields, fields) {
+		t.Fatalf("key [table]: invalid fields:\ngot= %+v\nwant=%+v", infos[1].Fields, fields)
+	}
+}
+
+func TestFileKeyInfosUpdate(t *testing.T) {
+	const fname = "testdata/file-keyinfos-update.hio"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	info := func() KeyInfo {
+		f, err := Open(fname)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer f.Close()
+		return f.KeyInfos()[0]
+	}
+
+	old := info()
+	if old.Entries != nentries {
+		t.Fatalf("expected [%d] entries. got %+v", nentries, old)
+	}
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s] for update: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		var table Table
+		err = f.Get(old.Name, &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table: %v", err)
+		}
+		for i := nentries; i < 2*nentries; i++ {
+			data := newTableData(i)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+	}()
+
+	cur := info()
+	if cur.Entries != 2*nentries || cur.Type != old.Type {
+		t.Fatalf("invalid key info after update:\nold=%+v\nnew=%+v", old, cur)
+	}
+	if cur.Size <= old.Size || cur.RawSize <= old.RawSize || cur.Time.Before(old.Time) {
+		t.Fatalf("sizes and time not updated:\nold=%+v\nnew=%+v", old, cur)
+	}
+}
+
+func TestFileKeyInfosOldFile(t *testing.T) {
+	const fname = "testdata/read-data.hio"
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	infos := f.KeyInfos()
+	if len(infos) != len(f.Keys()) {
+		t.Fatalf("expected [%d] keys. got [%d]", len(f.Keys()), len(infos))
+	}
+	for _, info := range infos {
+		if info.Type != "" || !info.Time.IsZero() || info.Size != info.Len {
+			t.Fatalf("unexpected description for key of old file: %+v", info)
+		}
+	}
+}
+
+func TestFileSetNil(t *testing.T) {
+	const fname = "testdata/file-set-nil.hio"
+	defer os.RemoveAll(fname)
+
+	f, err := Create(fname)
+	if err != nil {
+		t.Fatalf("could not create file [%s]: %v", fname, err)
+	}
+
+	for _, v := range []Value{nil, (*int64)(nil)} {
+		err = f.Set("nil", v)
+		if err == nil {
+			t.Fatalf("expected an error setting a nil value (%T)", v)
+		}
+	}
+	if f.Has("nil") {
+		t.Fatalf("expected no key [nil]")
+	}
+
+	if m := metaOf("nil", nil); m.Type != "" {
+		t.Fatalf("expected no type for a nil value. got %q", m.Type)
+	}
+
+	err = f.Close()
+	if err != nil {
+		t.Fatalf("could not close file [%s]: %v", fname, err)
+	}
+}
+
+// EOF
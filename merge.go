@@ -0,0 +1,365 @@
+package hio
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// MergeFunc adds the value pointed at by src to the value pointed at by dst.
+type MergeFunc func(dst, src Value) error
+
+type merger struct {
+	rt      reflect.Type
+	fn      MergeFunc
+	builtin bool // merges types of hbook, unless registered otherwise
+}
+
+var mergers = struct {
+	sync.RWMutex
+	m map[string]merger
+}{
+	m: make(map[string]merger),
+}
+
+// RegisterMerge makes Merge add the values of the type pointed at by ptr
+// with fn.
+// The 1-dim histograms of hbook (H1D) are added without registration, but
+// their merge function may be replaced.
+// RegisterMerge panics if ptr is not a pointer, if fn is nil or if a
+// function is already registered for that type.
+func RegisterMerge(ptr Value, fn MergeFunc) {
+	registerMerge(ptr, fn, false)
+}
+
+func registerMerge(ptr Value, fn MergeFunc, builtin bool) {
+	mergers.Lock()
+	defer mergers.Unlock()
+
+	rt := reflect.TypeOf(ptr)
+	if rt == nil || rt.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("hio: RegisterMerge needs a pointer (got %T)", ptr))
+	}
+	if fn == nil {
+		panic("hio: RegisterMerge fn is nil")
+	}
+	name := TypeName(rt.Elem())
+	if m, dup := mergers.m[name]; dup && !m.builtin {
+		panic("hio: RegisterMerge called twice for type [" + name + "]")
+	}
+	mergers.m[name] = merger{rt: rt.Elem(), fn: fn, builtin: builtin}
+}
+
+func mergerOf(typ string) (merger, bool) {
+	mergers.RLock()
+	defer mergers.RUnlock()
+
+	m, ok := mergers.m[typ]
+	return m, ok
+}
+
+// Merge creates the file dst holding the content of the srcs files, like
+// ROOT's hadd.
+//
+// Tables with the same name are concatenated, in the order of srcs: their
+// entries are copied without being decoded, so all these tables must have
+// the same type of entries, layout and codec.
+// Values with the same name are added with the function registered with
+// RegisterMerge for their type: 1-dim histograms of hbook are added bin by
+// bin, their under/overflows being left out. Values held by a single file,
+// or identical in all the files holding them, are copied. Directories of
+// all the files are kept.
+// Merge fails if a key is a table in a file and a value in another, if
+// values with the same name have different types, or if they differ and
+// can not be added. dst is then removed.
+//
+// The srcs files are read one at a time, so any number of them can be
+// merged: only the values being added are held in memory meanwhile.
+func Merge(dst string, srcs ...string) error {
+	if len(srcs) == 0 {
+		return fmt.Errorf("hio: no file to merge into [%s]", dst)
+	}
+
+	if fi, err := os.Stat(dst); err == nil {
+		for _, fname := range srcs {
+			si, err := os.Stat(fname)
+			if err == nil && os.SameFile(fi, si) {
+				return fmt.Errorf("hio: can not merge file [%s] into itself", fname)
+			}
+		}
+	}
+
+	out, err := Create(dst)
+	if err != nil {
+		return err
+	}
+
+	m := newFileMerger(out)
+	for _, fname := range srcs {
+		err = m.add(fname)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = m.flush()
+	}
+
+	if err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+
+	err = out.Close()
+	if err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return nil
+}
+
+// fileMerger merges files into a file, one at a time.
+type fileMerger struct {
+	out  *File
+	keys map[string]*mergedKey
+	vals []*mergedKey // values, in order of appearance
+}
+
+// mergedKey is the state of a key of the merged file.
+type mergedKey struct {
+	name  string
+	src   string // first file holding the key
+	table *Table // merged table
+	typ   string // type of the values, if recorded
+	tsrc  string // first file recording the type
+
+	// values.
+	raw  []byte  // record of the value in the first file
+	meta keyMeta // description of the value in the first file
+	fn   MergeFunc
+	sum  Value // sum of the values, for values added with fn
+	n    int   // number of files holding the value
+}
+
+func newFileMerger(out *File) *fileMerger {
+	return &fileMerger{
+		out:  out,
+		keys: make(map[string]*mergedKey),
+	}
+}
+
+// add merges the content of the named file.
+func (m *fileMerger) add(fname string) error {
+	src, err := Open(fname)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	for _, dir := range src.dirs {
+		_, err = m.out.Mkdir(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range src.Keys() {
+		err = m.merge(src, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// merge merges the named key of file src.
+func (m *fileMerger) merge(src *File, name string) error {
+	table := src.isTable(name)
+	key, ok := m.keys[name]
+	if !ok {
+		key = &mergedKey{name: name, src: src.Name()}
+		m.keys[name] = key
+		if !table {
+			m.vals = append(m.vals, key)
+		}
+	}
+
+	if ok && (key.table != nil) != table {
+		tfile, vfile := key.src, src.Name()
+		if !table {
+			tfile, vfile = vfile, tfile
+		}
+		return fmt.Errorf(
+			"hio: key [%s] is a table in file [%s] but not in file [%s]",
+			name, tfile, vfile,
+		)
+	}
+
+	if t := src.meta[name].Type; t != "" {
+		switch {
+		case key.typ == "":
+			key.typ = t
+			key.tsrc = src.Name()
+		case t != key.typ:
+			return fmt.Errorf(
+				"hio: key [%s] has type [%s] in file [%s] and type [%s] in file [%s]",
+				name, key.typ, key.tsrc, t, src.Name(),
+			)
+		}
+	}
+
+	if table {
+		return m.mergeTable(key, src)
+	}
+	return m.mergeValue(key, src)
+}
+
+// mergeValue adds the value of file src to the merged value.
+//
+// Whether values can be added is decided from the first file holding them:
+// other values are only compared to the first one.
+func (m *fileMerger) mergeValue(key *mergedKey, src *File) error {
+	name := key.name
+	key.n++
+
+	if key.n > 1 && key.fn != nil {
+		v := reflect.New(reflect.TypeOf(key.sum).Elem()).Interface()
+		err := src.Get(name, v)
+		if err != nil {
+			return err
+		}
+		err = key.fn(key.sum, v)
+		if err != nil {
+			return fmt.Errorf("hio: could not merge key [%s] of file [%s]: %v", name, src.Name(), err)
+		}
+		return nil
+	}
+
+	i := src.footer.getidx(name)
+	if i < 0 {
+		return fmt.Errorf("hio: no such key [%s] in footer of file [%s]", name, src.Name())
+	}
+	recname, _ := splitCycle(name)
+	raw, err := src.rawRecord(recname, src.footer.Keys[i].Pos)
+	if err != nil {
+		return err
+	}
+
+	if key.n > 1 {
+		if !bytes.Equal(raw, key.raw) {
+			return fmt.Errorf(
+				"hio: key [%s] differs in files [%s] and [%s], and values of type [%s] can not be added (missing RegisterMerge?)",
+				name, key.src, src.Name(), key.typ,
+			)
+		}
+		return nil
+	}
+
+	meta, ok := src.meta[name]
+	if !ok {
+		meta = keyMeta{Name: name}
+	}
+	key.raw = raw
+	key.meta = meta
+
+	mrg, ok := mergerOf(key.typ)
+	if !ok {
+		return nil
+	}
+	key.fn = mrg.fn
+	key.sum = reflect.New(mrg.rt).Interface()
+	return src.Get(name, key.sum)
+}
+
+// mergeTable appends the entries of the table of file src to the merged
+// table.
+func (m *fileMerger) mergeTable(key *mergedKey, src *File) error {
+	name := key.name
+	var t Table
+	err := src.Get(name, &t)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	if n := t.hdr.Entries; n > 0 && !t.indexed(n-1) {
+		return fmt.Errorf("hio: table [%s] of file [%s] has no index", name, src.Name())
+	}
+
+	if key.table == nil {
+		key.table, err = m.out.newTableLike(&t)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = key.table.compatible(&t)
+		if err != nil {
+			return fmt.Errorf(
+				"hio: table [%s] of file [%s] can not be merged with that of file [%s]: %v",
+				name, src.Name(), key.src, err,
+			)
+		}
+	}
+
+	return key.table.appendTable(src, &t)
+}
+
+// flush writes the merged values: values held by a single file, or which
+// can not be added, are written as they are stored on file.
+func (m *fileMerger) flush() error {
+	for _, key := range m.vals {
+		if key.fn != nil && key.n > 1 {
+			err := m.out.Set(key.name, key.sum)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		err := m.out.addRaw(key.name, key.raw, key.meta)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isTable returns whether the named key is a table.
+func (f *File) isTable(name string) bool {
+	if m, ok := f.meta[name]; ok {
+		return m.Table
+	}
+
+	// files written by older versions of hio do not describe their keys.
+	var table Table
+	err := f.Get(name, &table)
+	if err != nil {
+		return false
+	}
+	table.Close()
+	return true
+}
+
+// compatible returns an error if the entries of table t can not be appended
+// as they are stored on file to table.
+func (table *Table) compatible(t *Table) error {
+	switch {
+	case table.hdr.Type != "" && t.hdr.Type != "" && table.hdr.Type != t.hdr.Type:
+		return fmt.Errorf("entries of types [%s] and [%s]", table.hdr.Type, t.hdr.Type)
+	case (table.hdr.Cluster > 0) != (t.hdr.Cluster > 0) || table.basketed() != t.basketed():
+		return fmt.Errorf("different layouts")
+	case table.hdr.Codec != t.hdr.Codec:
+		return fmt.Errorf("codecs [%s] and [%s]", table.hdr.Codec, t.hdr.Codec)
+	}
+
+	if len(table.idx.Columns) > 0 && len(t.idx.Columns) > 0 {
+		if !reflect.DeepEqual(table.idx.Columns, t.idx.Columns) {
+			return fmt.Errorf("columns %v and %v", table.idx.Columns, t.idx.Columns)
+		}
+	}
+	return nil
+}
+
+// EOF
@@ -0,0 +1,268 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/go-hep/hbook"
+)
+
+type mergeCount struct {
+	N int64
+}
+
+func init() {
+	RegisterMerge(new(mergeCount), func(dst, src Value) error {
+		dst.(*mergeCount).N += src.(*mergeCount).N
+		return nil
+	})
+}
+
+func TestMerge(t *testing.T) {
+	const dst = "testdata/merge-out.hio"
+	const nfiles = 3
+	const nentries = 25
+	defer os.RemoveAll(dst)
+
+	srcs := make([]string, nfiles)
+	for k := range srcs {
+		srcs[k] = fmt.Sprintf("testdata/merge-%d.hio", k)
+		defer os.RemoveAll(srcs[k])
+
+		func() {
+			f, err := Create(srcs[k])
+			if err != nil {
+				t.Fatalf("could not create file [%s]: %v", srcs[k], err)
+			}
+			defer func() {
+				err = f.Close()
+				if err != nil {
+					t.Fatalf("could not close file [%s]: %v", srcs[k], err)
+				}
+			}()
+
+			rows, err := NewTable(f, "rows", WithCompression("flate", 5))
+			if err != nil {
+				t.Fatalf("could not create table: %v", err)
+			}
+			cols, err := NewTable(f, "cols", WithColumns(10))
+			if err != nil {
+				t.Fatalf("could not create table: %v", err)
+			}
+			bkts, err := NewTable(f, "bkts", WithBaskets(4, 0))
+			if err != nil {
+				t.Fatalf("could not create table: %v", err)
+			}
+
+			for i := k * nentries; i < (k+1)*nentries; i++ {
+				data := newTableData(i)
+				err = rows.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write entry [%d]: %v", i, err)
+				}
+				col := newColData(i)
+				err = cols.Write(&col)
+				if err != nil {
+					t.Fatalf("could not write entry [%d]: %v", i, err)
+				}
+				err = bkts.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write entry [%d]: %v", i, err)
+				}
+			}
+
+			err = f.Set("count", &mergeCount{N: int64(k + 1)})
+			if err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+			name := "run-1"
+			err = f.Set("name", &name)
+			if err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+		}()
+	}
+
+	err := Merge(dst, srcs...)
+	if err != nil {
+		t.Fatalf("could not merge files: %v", err)
+	}
+
+	f, err := Open(dst)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", dst, err)
+	}
+	defer f.Close()
+
+	if keys, want := f.Keys(), []string{"bkts", "cols", "count", "name", "rows"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected keys %v. got %v", want, keys)
+	}
+
+	var count mergeCount
+	err = f.Get("count", &count)
+	if err != nil {
+		t.Fatalf("could not get value: %v", err)
+	}
+	if want := int64(nfiles * (nfiles + 1) / 2); count.N != want {
+		t.Fatalf("expected merged count [%d]. got [%d]", want, count.N)
+	}
+
+	var name string
+	err = f.Get("name", &name)
+	if err != nil {
+		t.Fatalf("could not get value: %v", err)
+	}
+	if name != "run-1" {
+		t.Fatalf("expected identical value to be copied. got %q", name)
+	}
+
+	for _, test := range []struct {
+		name string
+		ref  func(i int) interface{}
+	}{
+		{"rows", func(i int) interface{} { return newTableData(i) }},
+		{"cols", func(i int) interface{} { return newColData(i) }},
+		{"bkts", func(i int) interface{} { return newTableData(i) }},
+	} {
+		func() {
+			var table Table
+			err = f.Get(test.name, &table)
+			if err != nil {
+				t.Fatalf("could not retrieve table [%s]: %v", test.name, err)
+			}
+			defer table.Close()
+
+			if n := table.Entries(); n != nfiles*nentries {
+				t.Fatalf("%s: expected [%d] entries. got [%d]", test.name, nfiles*nentries, n)
+			}
+
+			for i := 0; i < nfiles*nentries; i++ {
+				ref := test.ref(i)
+				ptr := reflect.New(reflect.TypeOf(ref))
+				err = table.Read(ptr.Interface())
+				if err != nil {
+					t.Fatalf("%s: could not read entry [%d]: %v", test.name, i, err)
+				}
+				if !reflect.DeepEqual(ptr.Elem().Interface(), ref) {
+					t.Fatalf("%s: expected (n=%d):\nref=%v\nnew=%v", test.name, i, ref, ptr.Elem().Interface())
+				}
+			}
+
+			ptr := reflect.New(reflect.TypeOf(test.ref(0)))
+			err = table.Read(ptr.Interface())
+			if err != io.EOF {
+				t.Fatalf("%s: expected io.EOF. got %v", test.name, err)
+			}
+		}()
+	}
+
+	for _, info := range f.KeyInfos() {
+		if info.Table && info.Entries != nfiles*nentries {
+			t.Fatalf("%s: expected [%d] entries in footer. got [%d]", info.Name, nfiles*nentries, info.Entries)
+		}
+	}
+}
+
+func TestMergeConflict(t *testing.T) {
+	const dst = "testdata/merge-conflict-out.hio"
+	srcs := []string{"testdata/merge-conflict-0.hio", "testdata/merge-conflict-1.hio"}
+	defer os.RemoveAll(dst)
+	for _, fname := range srcs {
+		defer os.RemoveAll(fname)
+	}
+
+	for _, test := range []struct {
+		name   string
+		create func(f *File, k int) error
+	}{
+		{
+			name: "table-value",
+			create: func(f *File, k int) error {
+				if k == 0 {
+					_, err := NewTable(f, "key")
+					return err
+				}
+				v := int64(42)
+				return f.Set("key", &v)
+			},
+		},
+		{
+			name: "types",
+			create: func(f *File, k int) error {
+				if k == 0 {
+					v := int64(42)
+					return f.Set("key", &v)
+				}
+				v := 42.0
+				return f.Set("key", &v)
+			},
+		},
+		{
+			name: "values",
+			create: func(f *File, k int) error {
+				v := int64(42 + k)
+				return f.Set("key", &v)
+			},
+		},
+		{
+			name: "binnings",
+			create: func(f *File, k int) error {
+				return f.Set("key", hbook.NewH1D(10+k, 0, 10))
+			},
+		},
+		{
+			name: "edges",
+			create: func(f *File, k int) error {
+				return f.Set("key", hbook.NewH1D(10, float64(k), float64(10+k)))
+			},
+		},
+		{
+			name: "layouts",
+			create: func(f *File, k int) error {
+				var opts []TableOption
+				if k == 0 {
+					opts = append(opts, WithColumns(10))
+				}
+				table, err := NewTable(f, "key", opts...)
+				if err != nil {
+					return err
+				}
+				data := newColData(k)
+				return table.Write(&data)
+			},
+		},
+	} {
+		for k, fname := range srcs {
+			f, err := Create(fname)
+			if err != nil {
+				t.Fatalf("%s: could not create file [%s]: %v", test.name, fname, err)
+			}
+			err = test.create(f, k)
+			if err != nil {
+				t.Fatalf("%s: could not fill file [%s]: %v", test.name, fname, err)
+			}
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("%s: could not close file [%s]: %v", test.name, fname, err)
+			}
+		}
+
+		err := Merge(dst, srcs...)
+		if err == nil {
+			t.Fatalf("%s: expected an error", test.name)
+		}
+		if _, err := os.Stat(dst); !os.IsNotExist(err) {
+			t.Fatalf("%s: expected partial file [%s] to be removed (err=%v)", test.name, dst, err)
+		}
+	}
+
+	err := Merge(srcs[0], srcs...)
+	if err == nil {
+		t.Fatalf("expected an error merging a file into itself")
+	}
+}
+
+// EOF
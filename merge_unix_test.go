@@ -0,0 +1,101 @@
+//go:build unix
+
+package hio
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestMergeManyFiles(t *testing.T) {
+	const dst = "testdata/merge-many-out.hio"
+	const nfiles = 100
+	const nentries = 3
+	defer os.RemoveAll(dst)
+
+	srcs := make([]string, nfiles)
+	for k := range srcs {
+		srcs[k] = fmt.Sprintf("testdata/merge-many-%d.hio", k)
+		defer os.RemoveAll(srcs[k])
+
+		func() {
+			f, err := Create(srcs[k])
+			if err != nil {
+				t.Fatalf("could not create file [%s]: %v", srcs[k], err)
+			}
+			defer func() {
+				err = f.Close()
+				if err != nil {
+					t.Fatalf("could not close file [%s]: %v", srcs[k], err)
+				}
+			}()
+
+			rows, err := NewTable(f, "rows")
+			if err != nil {
+				t.Fatalf("could not create table: %v", err)
+			}
+			for i := k * nentries; i < (k+1)*nentries; i++ {
+				data := newTableData(i)
+				err = rows.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write entry [%d]: %v", i, err)
+				}
+			}
+			err = f.Set("count", &mergeCount{N: 1})
+			if err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+		}()
+	}
+
+	// files are merged one at a time: far fewer descriptors than files
+	// are needed.
+	var lim syscall.Rlimit
+	err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &lim)
+	if err != nil {
+		t.Fatalf("could not get limit of open files: %v", err)
+	}
+	low := lim
+	low.Cur = nfiles / 2
+	err = syscall.Setrlimit(syscall.RLIMIT_NOFILE, &low)
+	if err != nil {
+		t.Skipf("could not set limit of open files: %v", err)
+	}
+	err = Merge(dst, srcs...)
+	e := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lim)
+	if e != nil {
+		t.Fatalf("could not restore limit of open files: %v", e)
+	}
+	if err != nil {
+		t.Fatalf("could not merge files: %v", err)
+	}
+
+	f, err := Open(dst)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", dst, err)
+	}
+	defer f.Close()
+
+	var count mergeCount
+	err = f.Get("count", &count)
+	if err != nil {
+		t.Fatalf("could not get value: %v", err)
+	}
+	if count.N != nfiles {
+		t.Fatalf("expected merged count [%d]. got [%d]", nfiles, count.N)
+	}
+
+	var rows Table
+	err = f.Get("rows", &rows)
+	if err != nil {
+		t.Fatalf("could not retrieve table: %v", err)
+	}
+	defer rows.Close()
+	if n := rows.Entries(); n != nfiles*nentries {
+		t.Fatalf("expected [%d] entries. got [%d]", nfiles*nentries, n)
+	}
+}
+
+// EOF
@@ -0,0 +1,167 @@
+package hio
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/go-hep/rio"
+)
+
+// entryRange is the half-open range [beg, end) of entry indices.
+type entryRange struct {
+	beg, end int64
+}
+
+// ReadParallel reads the entries of the table with nworkers goroutines,
+// each reading from its own stream on the file.
+// If nworkers is not positive, runtime.GOMAXPROCS(0) goroutines are used.
+//
+// For each entry, newPtr is called to get a pointer to a new value the
+// entry is read into, and fn is called with the index of the entry and
+// that pointer. fn is called concurrently, in no particular order.
+//
+// The selection of the table applies: its Filter must be safe for
+// concurrent use.
+// Reading stops at the first error, which is returned, or when ctx is done.
+// ReadParallel does not change the entry read by the next call to Read.
+func (table *Table) ReadParallel(ctx context.Context, nworkers int, newPtr func() interface{}, fn func(i int64, ptr interface{}) error) error {
+	if table.stream == nil {
+		return fmt.Errorf("hio: table [%s] is closed", table.hdr.Name)
+	}
+	if table.file != nil {
+		return fmt.Errorf("hio: table [%s] is open for writing", table.hdr.Name)
+	}
+	if n := table.hdr.Entries; n > 0 && !table.indexed(n-1) {
+		return fmt.Errorf("hio: table [%s] has no index", table.hdr.Name)
+	}
+	if nworkers <= 0 {
+		nworkers = runtime.GOMAXPROCS(0)
+	}
+
+	wctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	work := make(chan entryRange)
+	errs := make(chan error, nworkers)
+	var wg sync.WaitGroup
+	for w := 0; w < nworkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := table.readRanges(wctx, work, newPtr, fn)
+			if err != nil {
+				errs <- err
+				cancel()
+			}
+		}()
+	}
+
+loop:
+	for _, r := range table.ranges(nworkers) {
+		select {
+		case work <- r:
+		case <-wctx.Done():
+			break loop
+		}
+	}
+	close(work)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// readRanges reads the entries of the ranges sent on work, until work is
+// closed or ctx is done.
+func (table *Table) readRanges(ctx context.Context, work <-chan entryRange, newPtr func() interface{}, fn func(i int64, ptr interface{}) error) error {
+	stream, err := rio.Open(table.stream.Name())
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	w := &Table{
+		hdr:    table.hdr,
+		stream: stream,
+		idx:    table.idx,
+		sel:    table.sel,
+	}
+
+	for r := range work {
+		for i := r.beg; i < r.end; i++ {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			ptr := newPtr()
+			err = w.ReadAt(i, ptr)
+			if err != nil {
+				return err
+			}
+
+			ok, err := w.match(ptr)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			err = fn(i, ptr)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ranges splits the entries of the table in ranges to be read by n
+// workers.
+// Columnar tables are split along clusters, dropping the clusters excluded
+// by the selection, and basket tables along baskets.
+func (table *Table) ranges(n int) []entryRange {
+	var rs []entryRange
+
+	if table.hdr.Cluster > 0 {
+		cuts := table.sel != nil && len(table.sel.Cuts) > 0
+		first := int64(0)
+		for _, cluster := range table.idx.Clusters {
+			if !cuts || !table.excluded(cluster) {
+				rs = append(rs, entryRange{first, first + cluster.Entries})
+			}
+			first += cluster.Entries
+		}
+		return rs
+	}
+
+	if table.basketed() {
+		first := int64(0)
+		for _, basket := range table.idx.Baskets {
+			rs = append(rs, entryRange{first, first + basket.Entries})
+			first += basket.Entries
+		}
+		return rs
+	}
+
+	size := (table.hdr.Entries + int64(4*n) - 1) / int64(4*n)
+	if size < 1 {
+		size = 1
+	}
+	for beg := int64(0); beg < table.hdr.Entries; beg += size {
+		end := beg + size
+		if end > table.hdr.Entries {
+			end = table.hdr.Entries
+		}
+		rs = append(rs, entryRange{beg, end})
+	}
+	return rs
+}
+
+// EOF
@@ -0,0 +1,138 @@
+package hio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestTableReadParallel(t *testing.T) {
+	const fname = "testdata/table-read-parallel.hio"
+	const tname = "my-table"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer table.Close()
+
+	var mu sync.Mutex
+	entries := make(map[int64]tableData)
+	err = table.ReadParallel(
+		context.Background(), 3,
+		func() interface{} { return new(tableData) },
+		func(i int64, ptr interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+			entries[i] = *ptr.(*tableData)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("could not read table: %v", err)
+	}
+
+	if len(entries) != nentries {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries, len(entries))
+	}
+	for i, data := range entries {
+		if !reflect.DeepEqual(data, newTableData(int(i))) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newTableData(int(i)), data)
+		}
+	}
+
+	err = table.ReadParallel(
+		context.Background(), 3,
+		func() interface{} { return new(tableData) },
+		func(i int64, ptr interface{}) error {
+			if i == 5 {
+				return fmt.Errorf("error at entry [%d]", i)
+			}
+			return nil
+		},
+	)
+	if err == nil || err.Error() != "error at entry [5]" {
+		t.Fatalf("expected an error from entry [5]. got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = table.ReadParallel(
+		ctx, 3,
+		func() interface{} { return new(tableData) },
+		func(i int64, ptr interface{}) error { return nil },
+	)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled. got %v", err)
+	}
+}
+
+func TestTableReadParallelColumns(t *testing.T) {
+	const fname = "testdata/table-read-parallel-columns.hio"
+	const tname = "my-table"
+	const nentries = 45
+	defer os.RemoveAll(fname)
+
+	testColumnsCreate(t, fname, nentries, false)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	err = table.Select(&Selection{
+		Cuts: []Cut{{Column: "Px", Min: 15, Max: math.Inf(+1)}},
+	})
+	if err != nil {
+		t.Fatalf("could not select entries: %v", err)
+	}
+
+	var mu sync.Mutex
+	entries := make(map[int64]colData)
+	err = table.ReadParallel(
+		context.Background(), 0,
+		func() interface{} { return new(colData) },
+		func(i int64, ptr interface{}) error {
+			mu.Lock()
+			defer mu.Unlock()
+			entries[i] = *ptr.(*colData)
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("could not read table: %v", err)
+	}
+
+	if len(entries) != nentries-15 {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries-15, len(entries))
+	}
+	for i, data := range entries {
+		if !reflect.DeepEqual(data, newColData(int(i))) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newColData(int(i)), data)
+		}
+	}
+}
+
+// EOF
@@ -0,0 +1,463 @@
+package hio
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-hep/rio"
+)
+
+// Recover rebuilds the footer of the named file from the records it holds.
+//
+// Recover is meant for files whose writer died before File.Close. The file
+// is recovered from its last valid footer, written by Close or by
+// Checkpoint, with the description of its keys and its directories: the
+// values, tables and table entries written after that footer are added from
+// the records actually present on file, and a fresh footer is written.
+// Files with no valid footer are reconstructed from all their records.
+// A record truncated by the crash, and anything past it, is discarded.
+func Recover(fname string) error {
+	idx, err := scanFile(fname)
+	if err != nil {
+		return err
+	}
+
+	f, err := reopen(fname, idx.header, FileFooter{Keys: idx.keys}, idx.meta, idx.begin, idx.end)
+	if err != nil {
+		return err
+	}
+	f.dirs = idx.dirs
+
+	err = idx.recover(f)
+	if err != nil {
+		f.f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// recover adds to f, holding the keys of the last footer, the entries
+// written to its tables after that footer, and the tables created since.
+func (idx fileIndex) recover(f *File) error {
+	for _, key := range idx.keys {
+		if !idx.isTable(key.Name) || idx.bytes[key.Name] == 0 {
+			continue
+		}
+		table := new(Table)
+		err := f.Get(key.Name, table)
+		if err != nil {
+			return err
+		}
+		idx.extend(table)
+	}
+
+	for _, item := range idx.tables.slice {
+		table := &Table{
+			hdr:    *idx.hdrs[item.k],
+			stream: f.f,
+		}
+		// the header was written before any entry.
+		table.hdr.Entries = 0
+		table.hdr.Index = 0
+		idx.extend(table)
+
+		err := f.dict.Set(item.k, table)
+		if err != nil {
+			return err
+		}
+		f.tables.set(item.k, item.v)
+	}
+
+	return nil
+}
+
+// extend adds to the index of table the entries written after the last
+// footer.
+func (idx fileIndex) extend(table *Table) {
+	name := table.hdr.Name
+	switch {
+	case table.hdr.Cluster > 0:
+		if len(table.idx.Columns) == 0 {
+			table.idx.Columns = idx.columns[name]
+		}
+		for _, cluster := range idx.clusters(name, table.idx.Columns) {
+			table.idx.Clusters = append(table.idx.Clusters, cluster)
+			table.hdr.Entries += cluster.Entries
+		}
+	case table.basketed():
+		recname := bktrecname(name)
+		for i, pos := range idx.offsets[recname] {
+			n := idx.nentries[recname][i]
+			table.idx.Baskets = append(table.idx.Baskets, basketIndex{Entries: n, Offset: pos})
+			table.hdr.Entries += n
+		}
+	default:
+		if len(table.idx.Columns) == 0 {
+			table.idx.Columns = idx.fields[name]
+		}
+		table.idx.Offsets = append(table.idx.Offsets, idx.offsets[name]...)
+		table.hdr.Entries += int64(len(idx.offsets[name]))
+	}
+	table.nbytes += idx.bytes[name]
+}
+
+// fileIndex is the index of a file, as reconstructed by scanFile.
+type fileIndex struct {
+	header FileHeader
+	begin  int64 // start of file payload
+	end    int64 // end of the last valid record
+
+	// keys of the last footer, with the values written after it.
+	keys []fileEntry
+	meta map[string]keyMeta
+	dirs []string
+
+	hdrs map[string]*tableHeader // headers of all the tables
+
+	// records written after the last footer.
+	tables  pmap               // position of table headers
+	offsets map[string][]int64 // position of each record, by name
+	bytes   map[string]int64   // bytes used by the records of each table
+
+	columns  map[string][]string   // columns of each columnar table
+	nentries map[string][]int64    // entries in each column or basket record, by name
+	stats    map[string][]colStats // statistics of each column record, by name
+	fields   map[string][]string   // fields of each row-wise table of structs
+}
+
+// since resets the description of the records written after the last
+// footer.
+func (idx *fileIndex) since() {
+	idx.tables = newpmap()
+	idx.offsets = make(map[string][]int64)
+	idx.bytes = make(map[string]int64)
+	idx.columns = make(map[string][]string)
+	idx.nentries = make(map[string][]int64)
+	idx.stats = make(map[string][]colStats)
+	idx.fields = make(map[string][]string)
+}
+
+// isTable returns whether the named key of the last footer is a table.
+func (idx fileIndex) isTable(name string) bool {
+	if m, ok := idx.meta[name]; ok {
+		return m.Table
+	}
+	return idx.hdrs[name] != nil
+}
+
+// clusters returns the clusters of the named columnar table written after
+// the last footer.
+// Clusters which have not been completely written are dropped.
+func (idx fileIndex) clusters(name string, columns []string) []clusterIndex {
+	if len(columns) == 0 {
+		return nil
+	}
+
+	n := -1
+	for _, col := range columns {
+		if nrecs := len(idx.offsets[colrecname(name, col)]); n < 0 || nrecs < n {
+			n = nrecs
+		}
+	}
+
+	var clusters []clusterIndex
+	for k := 0; k < n; k++ {
+		cluster := clusterIndex{
+			Entries: idx.nentries[colrecname(name, columns[0])][k],
+			Offsets: make([]int64, len(columns)),
+			Stats:   make([]colStats, len(columns)),
+		}
+		for j, col := range columns {
+			cluster.Offsets[j] = idx.offsets[colrecname(name, col)][k]
+			cluster.Stats[j] = idx.stats[colrecname(name, col)][k]
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters
+}
+
+// valueRecord is a record holding a value, found by scanFile.
+type valueRecord struct {
+	entry fileEntry
+	cycle int64 // cycle of the value, 0 if unknown
+}
+
+// addValues adds to the keys of the last footer the values written after
+// it: each value either is a later version of a cycle of the footer, or
+// starts a new cycle.
+func (idx *fileIndex) addValues(vals []valueRecord) {
+	type cycleKey struct {
+		name  string
+		cycle int64
+	}
+
+	var (
+		keys    []fileEntry // tables
+		names   []string    // names of values, in order of appearance
+		latest  = make(map[string]int64)
+		entries = make(map[cycleKey]fileEntry)
+		metas   = make(map[cycleKey]keyMeta)
+		types   = make(map[string]string)
+	)
+	add := func(name string, cycle int64, entry fileEntry) {
+		if _, ok := latest[name]; !ok {
+			names = append(names, name)
+		}
+		if cycle > latest[name] {
+			latest[name] = cycle
+		}
+		entries[cycleKey{name, cycle}] = entry
+	}
+
+	// the latest cycle of a value of the footer follows its previous ones.
+	for _, key := range idx.keys {
+		if name, cycle := splitCycle(key.Name); cycle > 0 && cycle > latest[name] {
+			latest[name] = cycle
+		}
+	}
+	footer := latest
+	latest = make(map[string]int64)
+	for _, key := range idx.keys {
+		if idx.isTable(key.Name) {
+			keys = append(keys, key)
+			continue
+		}
+		name, cycle := splitCycle(key.Name)
+		if cycle == 0 {
+			cycle = footer[name] + 1
+		}
+		add(name, cycle, key)
+		if m, ok := idx.meta[key.Name]; ok {
+			metas[cycleKey{name, cycle}] = m
+			types[name] = m.Type
+		}
+	}
+
+	for _, val := range vals {
+		name := val.entry.Name
+		cycle := val.cycle
+		if cycle <= 0 {
+			cycle = latest[name] + 1
+		}
+		add(name, cycle, val.entry)
+		metas[cycleKey{name, cycle}] = keyMeta{Type: types[name]}
+	}
+
+	meta := make(map[string]keyMeta, len(idx.meta))
+	for _, key := range keys {
+		if m, ok := idx.meta[key.Name]; ok {
+			meta[key.Name] = m
+		}
+	}
+	for _, name := range names {
+		var cycles []int64
+		for k := range entries {
+			if k.name == name {
+				cycles = append(cycles, k.cycle)
+			}
+		}
+		sort.Slice(cycles, func(i, j int) bool { return cycles[i] < cycles[j] })
+
+		for _, cycle := range cycles {
+			k := cycleKey{name, cycle}
+			entry := entries[k]
+			entry.Name = name
+			if cycle != latest[name] {
+				entry.Name = cycleName(name, cycle)
+			}
+			keys = append(keys, entry)
+			if m, ok := metas[k]; ok {
+				m.Name = entry.Name
+				meta[entry.Name] = m
+			}
+		}
+	}
+
+	idx.keys = keys
+	idx.meta = meta
+}
+
+// scanFile walks the records of the named file and reconstructs its index.
+func scanFile(fname string) (fileIndex, error) {
+	idx := fileIndex{
+		keys: make([]fileEntry, 0),
+		meta: make(map[string]keyMeta),
+		hdrs: make(map[string]*tableHeader),
+	}
+	idx.since()
+
+	f, err := rio.Open(fname)
+	if err != nil {
+		return idx, err
+	}
+	defer f.Close()
+
+	idx.header, err = newFileHeaderFrom(f)
+	if err != nil {
+		return idx, err
+	}
+	err = idx.header.check(fname)
+	if err != nil {
+		return idx, err
+	}
+	idx.begin = f.CurPos()
+	idx.end = idx.begin
+
+	// first pass: no record is requested, so rio goes through the whole
+	// file, registering the name of every record it encounters.
+	// the error is the one of the end of file (or of a truncated record.)
+	_, _ = f.ReadRecord()
+
+	_, err = f.Seek(idx.begin, 0)
+	if err != nil {
+		return idx, err
+	}
+
+	// second pass: request every record, decoding only footers, table
+	// headers, the fields of row-wise tables, the number of entries of
+	// baskets, the number of entries and statistics of column records and
+	// the cycle of values.
+	var (
+		ftr  FileFooter
+		meta []keyMeta
+		dirs []string
+	)
+	counts := make(map[string]*int64)
+	stats := make(map[string]*colStats)
+	fields := make(map[string]*[]string)
+	cycles := make(map[string]*int64)
+	for _, rec := range f.Records() {
+		name := rec.Name()
+		rec.SetUnpack(true)
+		switch {
+		case name == "hio.FileHeader" || strings.HasPrefix(name, "hio.Index/"):
+			// nothing to decode.
+		case name == "hio.FileFooter":
+			err = rec.Connect("hio.FileFooter", &ftr)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+			err = rec.Connect("hio.KeyMeta", &meta)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+			err = rec.Connect("hio.Dirs", &dirs)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+		case strings.HasPrefix(name, "hio.Basket/"):
+			n := new(int64)
+			counts[name] = n
+			err = rec.Connect("hio.Entries", n)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+		case strings.HasPrefix(name, "hio.Column/"):
+			n := new(int64)
+			counts[name] = n
+			err = rec.Connect("hio.Entries", n)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+			st := new(colStats)
+			stats[name] = st
+			err = rec.Connect("hio.Stats", st)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+		case strings.HasPrefix(name, "hio.Fields/"):
+			names := new([]string)
+			fields[strings.TrimPrefix(name, "hio.Fields/")] = names
+			err = rec.Connect("hio.Fields", names)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+		case strings.HasPrefix(name, "hio.Header/"):
+			hdr := &tableHeader{}
+			idx.hdrs[strings.TrimPrefix(name, "hio.Header/")] = hdr
+			err = hdr.connect(rec)
+			if err != nil {
+				return idx, err
+			}
+		default:
+			// values, or entries of row-wise tables.
+			cycle := new(int64)
+			cycles[name] = cycle
+			err = rec.Connect("hio.Cycle", cycle)
+			if err != nil && err != rio.ErrBlockConnected {
+				return idx, err
+			}
+		}
+	}
+
+	var vals []valueRecord // values written after the last footer
+	for {
+		pos := f.CurPos()
+		rec, err := f.ReadRecord()
+		if err != nil {
+			// end of file or truncated record.
+			break
+		}
+
+		name := rec.Name()
+		size := f.CurPos() - pos
+		switch {
+		case name == "hio.FileHeader":
+			continue
+		case name == "hio.FileFooter":
+			// everything written so far is described by the footer, which
+			// is rewritten by Recover.
+			idx.keys = ftr.Keys
+			idx.meta = make(map[string]keyMeta, len(meta))
+			for _, m := range meta {
+				idx.meta[m.Name] = m
+			}
+			idx.dirs = dirs
+			ftr, meta, dirs = FileFooter{}, nil, nil
+			vals = nil
+			idx.since()
+			continue
+		case strings.HasPrefix(name, "hio.Index/"):
+			// table index of the following footer.
+		case strings.HasPrefix(name, "hio.Header/"):
+			idx.tables.set(strings.TrimPrefix(name, "hio.Header/"), pos)
+		case strings.HasPrefix(name, "hio.Basket/"):
+			idx.offsets[name] = append(idx.offsets[name], pos)
+			idx.nentries[name] = append(idx.nentries[name], *counts[name])
+			idx.bytes[strings.TrimPrefix(name, "hio.Basket/")] += size
+		case strings.HasPrefix(name, "hio.Fields/"):
+			table := strings.TrimPrefix(name, "hio.Fields/")
+			idx.fields[table] = *fields[table]
+			idx.bytes[table] += size
+		case strings.HasPrefix(name, "hio.Column/"):
+			path := strings.TrimPrefix(name, "hio.Column/")
+			table := path[:strings.LastIndex(path, "/")]
+			if len(idx.offsets[name]) == 0 {
+				idx.columns[table] = append(idx.columns[table], path[len(table)+1:])
+			}
+			idx.offsets[name] = append(idx.offsets[name], pos)
+			idx.nentries[name] = append(idx.nentries[name], *counts[name])
+			idx.stats[name] = append(idx.stats[name], *stats[name])
+			idx.bytes[table] += size
+		case idx.hdrs[name] != nil:
+			// entry of a row-wise table.
+			idx.offsets[name] = append(idx.offsets[name], pos)
+			idx.bytes[name] += size
+		default:
+			val := valueRecord{entry: fileEntry{Name: name, Pos: pos, Len: size}}
+			if cycle := cycles[name]; cycle != nil {
+				val.cycle = *cycle
+				*cycle = 0
+			}
+			vals = append(vals, val)
+		}
+		idx.end = f.CurPos()
+	}
+
+	idx.addValues(vals)
+	return idx, nil
+}
+
+// EOF
@@ -0,0 +1,319 @@
+package hio
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	const fname = "testdata/recover.hio"
+	const tname = "my-table"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+
+	for _, test := range []struct {
+		name     string
+		truncate int64 // number of bytes chopped off the end of file
+		entries  int64
+	}{
+		{
+			name:     "no-footer",
+			truncate: 0,
+			entries:  nentries,
+		},
+		{
+			name:     "truncated-record",
+			truncate: 4,
+			entries:  nentries - 1,
+		},
+	} {
+		func() {
+			f, err := Create(fname)
+			if err != nil {
+				t.Fatalf("%s: could not create file [%s]: %v", test.name, fname, err)
+			}
+
+			table, err := NewTable(f, tname)
+			if err != nil {
+				t.Fatalf("%s: could not create table [%s]: %v", test.name, tname, err)
+			}
+
+			for i := 0; i < nentries; i++ {
+				data := int64(i)
+				err = table.Write(&data)
+				if err != nil {
+					t.Fatalf("%s: could not write to table [i=%d]: %v", test.name, i, err)
+				}
+			}
+
+			// simulate a crash: the footer is never written.
+			err = f.f.Close()
+			if err != nil {
+				t.Fatalf("%s: could not close stream: %v", test.name, err)
+			}
+		}()
+
+		if test.truncate > 0 {
+			fi, err := os.Stat(fname)
+			if err != nil {
+				t.Fatalf("%s: could not stat file [%s]: %v", test.name, fname, err)
+			}
+			err = os.Truncate(fname, fi.Size()-test.truncate)
+			if err != nil {
+				t.Fatalf("%s: could not truncate file [%s]: %v", test.name, fname, err)
+			}
+		}
+
+		if f, err := Open(fname); err == nil {
+			f.Close()
+			t.Fatalf("%s: expected an error opening a crashed file", test.name)
+		}
+
+		err := Recover(fname)
+		if err != nil {
+			t.Fatalf("%s: could not recover file [%s]: %v", test.name, fname, err)
+		}
+
+		func() {
+			f, err := Open(fname)
+			if err != nil {
+				t.Fatalf("%s: could not open recovered file [%s]: %v", test.name, fname, err)
+			}
+			defer f.Close()
+
+			var table Table
+			err = f.Get(tname, &table)
+			if err != nil {
+				t.Fatalf("%s: could not retrieve table [%s]: %v", test.name, tname, err)
+			}
+			defer table.Close()
+
+			if table.Entries() != test.entries {
+				t.Fatalf("%s: expected [%d] entries. got [%d]", test.name, test.entries, table.Entries())
+			}
+
+			for i := int64(0); i < test.entries+1; i++ {
+				var data int64
+				err = table.Read(&data)
+				if i == test.entries {
+					if err != io.EOF {
+						t.Fatalf("%s: read too many entries (err=%#v)", test.name, err)
+					}
+					break
+				}
+				if err != nil {
+					t.Fatalf("%s: could not read table [i=%d]: %v", test.name, i, err)
+				}
+				if data != i {
+					t.Fatalf("%s: expected entry [%d] to be %d. got %d", test.name, i, i, data)
+				}
+			}
+		}()
+	}
+}
+
+func TestRecoverValidFile(t *testing.T) {
+	const fname = "testdata/recover-valid.hio"
+	defer os.RemoveAll(fname)
+	testFileCreateAndFill(t, fname)
+
+	err := Recover(fname)
+	if err != nil {
+		t.Fatalf("could not recover file [%s]: %v", fname, err)
+	}
+
+	testFileOpen(t, fname)
+}
+
+func TestRecoverFromFooter(t *testing.T) {
+	const fname = "testdata/recover-footer.hio"
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		for i := int64(1); i <= 2; i++ {
+			calib := i
+			err = f.Set("calib", &calib)
+			if err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+		}
+		x := int64(42)
+		err = f.Set("x", &x)
+		if err != nil {
+			t.Fatalf("could not set value: %v", err)
+		}
+		_, err = f.Mkdir("run1")
+		if err != nil {
+			t.Fatalf("could not create directory: %v", err)
+		}
+
+		table, err := NewTable(f, "t1")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		for i := 0; i < 10; i++ {
+			data := int64(i)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+
+		err = f.Del("x")
+		if err != nil {
+			t.Fatalf("could not delete key: %v", err)
+		}
+	}()
+
+	infos := func() []KeyInfo {
+		f, err := Open(fname)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer f.Close()
+		if !reflect.DeepEqual(f.dirs, []string{"run1"}) {
+			t.Fatalf("expected directories [run1]. got %v", f.dirs)
+		}
+		return f.KeyInfos()
+	}
+
+	// a valid file is left as it is.
+	want := infos()
+	err := Recover(fname)
+	if err != nil {
+		t.Fatalf("could not recover file [%s]: %v", fname, err)
+	}
+	if got := infos(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("invalid keys after recovery:\ngot= %+v\nwant=%+v", got, want)
+	}
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s] for update: %v", fname, err)
+		}
+
+		var t1 Table
+		err = f.Get("t1", &t1)
+		if err != nil {
+			t.Fatalf("could not retrieve table: %v", err)
+		}
+		t2, err := NewTable(f, "t2")
+		if err != nil {
+			t.Fatalf("could not create table: %v", err)
+		}
+		for i := 10; i < 15; i++ {
+			data := int64(i)
+			err = t1.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+			err = t2.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write entry [%d]: %v", i, err)
+			}
+		}
+
+		// the third cycle is written when the fourth one is set, which is
+		// lost in the crash.
+		for i := int64(3); i <= 4; i++ {
+			calib := i
+			err = f.Set("calib", &calib)
+			if err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+		}
+
+		// simulate a crash: the footer is never written.
+		err = f.f.Close()
+		if err != nil {
+			t.Fatalf("could not close stream: %v", err)
+		}
+	}()
+
+	err = Recover(fname)
+	if err != nil {
+		t.Fatalf("could not recover file [%s]: %v", fname, err)
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open recovered file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	if keys, want := f.Keys(), []string{"calib", "t1", "t2"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("expected keys %v. got %v", want, keys)
+	}
+	if !reflect.DeepEqual(f.dirs, []string{"run1"}) {
+		t.Fatalf("expected directories [run1]. got %v", f.dirs)
+	}
+
+	history, err := f.History("calib")
+	if err != nil {
+		t.Fatalf("could not retrieve history: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 cycles. got %+v", history)
+	}
+	for i, info := range history {
+		var calib int64
+		err = f.Get(info.Name, &calib)
+		if err != nil {
+			t.Fatalf("could not get value [%s]: %v", info.Name, err)
+		}
+		if calib != int64(i+1) || info.Cycle != int64(i+1) {
+			t.Fatalf("%s: expected cycle %d. got %d (cycle=%d)", info.Name, i+1, calib, info.Cycle)
+		}
+		if info.Type != "int64" {
+			t.Fatalf("%s: expected type [int64]. got [%s]", info.Name, info.Type)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		entries int64
+	}{
+		{"t1", 15},
+		{"t2", 5},
+	} {
+		func() {
+			var table Table
+			err = f.Get(test.name, &table)
+			if err != nil {
+				t.Fatalf("could not retrieve table [%s]: %v", test.name, err)
+			}
+			defer table.Close()
+
+			if table.Entries() != test.entries {
+				t.Fatalf("%s: expected [%d] entries. got [%d]", test.name, test.entries, table.Entries())
+			}
+			first := 15 - test.entries
+			for i := int64(0); i < test.entries; i++ {
+				var data int64
+				err = table.Read(&data)
+				if err != nil {
+					t.Fatalf("%s: could not read entry [%d]: %v", test.name, i, err)
+				}
+				if data != first+i {
+					t.Fatalf("%s: expected entry [%d] to be %d. got %d", test.name, i, first+i, data)
+				}
+			}
+		}()
+	}
+}
+
+// EOF
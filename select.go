@@ -0,0 +1,186 @@
+package hio
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Selection selects the entries of a table returned by Table.Read and
+// Table.ReadColumns.
+//
+// An entry is selected if the values of all the cut columns lie in their
+// range and if Filter, when set, returns true for it.
+// Clusters of columnar tables whose statistics show that no entry can pass
+// the cuts are skipped without being decoded.
+type Selection struct {
+	Cuts   []Cut                      // ranges of values of numeric columns
+	Filter func(ptr interface{}) bool // predicate on decoded entries
+}
+
+// Cut selects the entries whose value of the named numeric column lies in
+// [Min, Max].
+// Open ranges use math.Inf: Cut{"Pt", 50, math.Inf(+1)} selects Pt >= 50.
+type Cut struct {
+	Column string
+	Min    float64
+	Max    float64
+}
+
+// colStats holds the range of values of a numeric column over a cluster.
+type colStats struct {
+	Valid bool // whether Min and Max are meaningful
+	Min   float64
+	Max   float64
+}
+
+// Select makes Read and ReadColumns only return the entries of the table
+// matching sel, replacing any previous selection.
+// A nil selection selects all entries.
+// ReadAt ignores the selection.
+func (table *Table) Select(sel *Selection) error {
+	if sel != nil && len(table.idx.Columns) > 0 {
+		for _, cut := range sel.Cuts {
+			if table.idx.column(cut.Column) < 0 {
+				return fmt.Errorf("hio: no column [%s] in table [%s]", cut.Column, table.hdr.Name)
+			}
+		}
+	}
+	table.sel = sel
+	return nil
+}
+
+// Filter makes Read and ReadColumns skip the entries of the table for which
+// fn returns false, replacing any previous selection.
+func (table *Table) Filter(fn func(ptr interface{}) bool) {
+	table.sel = &Selection{Filter: fn}
+}
+
+// selected returns the named columns along with the ones the cuts of the
+// selection apply to.
+func (table *Table) selected(names []string) []string {
+	if table.sel == nil || len(names) == 0 {
+		return names
+	}
+
+	out := names
+	for _, cut := range table.sel.Cuts {
+		found := false
+		for _, name := range names {
+			if name == cut.Column {
+				found = true
+				break
+			}
+		}
+		if !found {
+			out = append(out[:len(out):len(out)], cut.Column)
+		}
+	}
+	return out
+}
+
+// match returns whether the entry pointed at by ptr is selected.
+func (table *Table) match(ptr interface{}) (bool, error) {
+	sel := table.sel
+	if sel == nil {
+		return true, nil
+	}
+
+	if len(sel.Cuts) > 0 {
+		rv, err := structOf(ptr)
+		if err != nil {
+			return false, err
+		}
+		for _, cut := range sel.Cuts {
+			fv := rv.FieldByName(cut.Column)
+			if !fv.IsValid() {
+				return false, fmt.Errorf("hio: no field [%s] in %T", cut.Column, ptr)
+			}
+			v, ok := numeric(fv)
+			if !ok {
+				return false, fmt.Errorf("hio: column [%s] of table [%s] is not numeric", cut.Column, table.hdr.Name)
+			}
+			if !(cut.Min <= v && v <= cut.Max) {
+				return false, nil
+			}
+		}
+	}
+
+	if sel.Filter != nil {
+		return sel.Filter(ptr), nil
+	}
+	return true, nil
+}
+
+// skip moves the index of the next entry to read past the clusters whose
+// statistics show that none of their entries passes the cuts.
+func (table *Table) skip() {
+	if table.sel == nil || len(table.sel.Cuts) == 0 || table.hdr.Cluster <= 0 {
+		return
+	}
+
+	first := int64(0)
+	for _, cluster := range table.idx.Clusters {
+		last := first + cluster.Entries
+		if table.cur >= last {
+			first = last
+			continue
+		}
+		if !table.excluded(cluster) {
+			return
+		}
+		table.cur = last
+		first = last
+	}
+}
+
+// excluded returns whether no entry of the cluster can pass the cuts.
+func (table *Table) excluded(cluster clusterIndex) bool {
+	for _, cut := range table.sel.Cuts {
+		j := table.idx.column(cut.Column)
+		if j < 0 || j >= len(cluster.Stats) || !cluster.Stats[j].Valid {
+			continue
+		}
+		st := cluster.Stats[j]
+		if st.Max < cut.Min || st.Min > cut.Max {
+			return true
+		}
+	}
+	return false
+}
+
+// statsOf returns the range of values of a slice of a numeric type.
+func statsOf(vs reflect.Value) colStats {
+	var st colStats
+	for i := 0; i < vs.Len(); i++ {
+		v, ok := numeric(vs.Index(i))
+		if !ok {
+			return colStats{}
+		}
+		if math.IsNaN(v) {
+			continue
+		}
+		if !st.Valid {
+			st = colStats{Valid: true, Min: v, Max: v}
+			continue
+		}
+		st.Min = math.Min(st.Min, v)
+		st.Max = math.Max(st.Max, v)
+	}
+	return st
+}
+
+// numeric returns the value of v as a float64, if v is a number.
+func numeric(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// EOF
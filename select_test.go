@@ -0,0 +1,132 @@
+package hio
+
+import (
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTableSelectColumns(t *testing.T) {
+	const fname = "testdata/table-select-columns.hio"
+	const tname = "my-table"
+	const nentries = 30
+	defer os.RemoveAll(fname)
+
+	testColumnsCreate(t, fname, nentries, false)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	for k, cluster := range table.idx.Clusters {
+		j := table.idx.column("Px")
+		st := cluster.Stats[j]
+		if !st.Valid || st.Min != float64(10*k) || st.Max != float64(10*k+9) {
+			t.Fatalf("invalid statistics for cluster [%d]: %+v", k, st)
+		}
+		if st := cluster.Stats[table.idx.column("Name")]; st.Valid {
+			t.Fatalf("unexpected statistics for non-numeric column: %+v", st)
+		}
+	}
+
+	err = table.Select(&Selection{
+		Cuts: []Cut{{Column: "Px", Min: 22, Max: math.Inf(+1)}},
+		Filter: func(ptr interface{}) bool {
+			return ptr.(*colData).N%2 == 0
+		},
+	})
+	if err != nil {
+		t.Fatalf("could not select entries: %v", err)
+	}
+
+	var data colData
+	err = table.Read(&data)
+	if err != nil {
+		t.Fatalf("could not read table: %v", err)
+	}
+	if want := newColData(22); !reflect.DeepEqual(data, want) {
+		t.Fatalf("expected:\nref=%v\nnew=%v", want, data)
+	}
+	if table.cols.cluster != 2 {
+		t.Fatalf("expected clusters [0, 1] to be skipped. got cluster [%d]", table.cols.cluster)
+	}
+
+	for _, i := range []int{24, 26, 28} {
+		var data colData
+		err = table.ReadColumns(&data, "Name", "N")
+		if err != nil {
+			t.Fatalf("could not read columns [i=%d]: %v", i, err)
+		}
+		if want := newColData(i); data.Name != want.Name || data.Px != want.Px {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, want, data)
+		}
+	}
+
+	err = table.Read(&data)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF. got %v", err)
+	}
+
+	err = table.Select(&Selection{Cuts: []Cut{{Column: "Energy"}}})
+	if err == nil {
+		t.Fatalf("expected an error selecting on a non-existing column")
+	}
+}
+
+func TestTableFilter(t *testing.T) {
+	const fname = "testdata/table-filter.hio"
+	const tname = "my-table"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer table.Close()
+
+	table.Filter(func(ptr interface{}) bool {
+		return ptr.(*tableData).Ints[0]%3 == 0
+	})
+
+	for i := 0; i < nentries; i++ {
+		if (i+100)%3 != 0 {
+			continue
+		}
+		var data tableData
+		err = table.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read table [i=%d]: %v", i, err)
+		}
+		if !reflect.DeepEqual(data, newTableData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newTableData(i), data)
+		}
+	}
+
+	var data tableData
+	err = table.Read(&data)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF. got %v", err)
+	}
+}
+
+// EOF
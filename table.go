@@ -4,53 +4,214 @@ import (
 	"bytes"
 	"encoding/gob"
 	"fmt"
+	"io"
+	"reflect"
 
 	"github.com/go-hep/rio"
 )
 
+// tableHeader describes a table on file.
+// The fields of the first version of tables are stored in the block named
+// after the header record, and the others in the "hio.Layout" block, so
+// headers written before them can still be read.
 type tableHeader struct {
+	tableInfo
+	tableLayout
+}
+
+// tableVersion is the version of the tables written by this package.
+// Tables of version 0 have no "hio.Layout" block: they are row-wise,
+// uncompressed by hio and have no index.
+const tableVersion = 1
+
+type tableInfo struct {
 	Name    string
 	Version uint32
 	Entries int64
 }
 
-func NewTable(f *File, name string) (*Table, error) {
+type tableLayout struct {
+	Type    string // type of the table elements, if known
+	Index   int64  // position of the latest chunk of the entries index on file
+	Cluster int64  // number of entries per cluster (columnar layout), 0 if row-wise
+	Codec   string // codec compressing the entries, empty if compressed by rio
+	Level   int64  // compression level of the codec
+
+	Basket      int64 // maximum number of entries per basket, 0 if unlimited
+	BasketBytes int64 // maximum size of a basket, 0 if unlimited
+}
+
+// connect connects the blocks of the header to the named record.
+func (hdr *tableHeader) connect(rec *rio.Record) error {
+	err := rec.Connect(rec.Name(), &hdr.tableInfo)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+	err = rec.Connect("hio.Layout", &hdr.tableLayout)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+	return nil
+}
+
+// tableIndex holds the position on file of each entry of a table.
+type tableIndex struct {
+	Offsets []int64 // row-wise layout: position of each entry
+
+	Columns  []string       // name of each column (field of struct entries)
+	Clusters []clusterIndex // columnar layout: clusters of entries
+	Baskets  []basketIndex  // baskets of entries
+}
+
+// indexCount counts the items of a table index.
+type indexCount struct {
+	columns  int
+	offsets  int
+	clusters int
+	baskets  int
+}
+
+// count returns the number of items of the index.
+func (idx tableIndex) count() indexCount {
+	return indexCount{
+		columns:  len(idx.Columns),
+		offsets:  len(idx.Offsets),
+		clusters: len(idx.Clusters),
+		baskets:  len(idx.Baskets),
+	}
+}
+
+// since returns the items of the index past the first n ones, along with
+// all the columns.
+func (idx tableIndex) since(n indexCount) tableIndex {
+	return tableIndex{
+		Offsets:  idx.Offsets[n.offsets:],
+		Columns:  idx.Columns,
+		Clusters: idx.Clusters[n.clusters:],
+		Baskets:  idx.Baskets[n.baskets:],
+	}
+}
+
+// add appends the items of chunk to the index.
+func (idx *tableIndex) add(chunk tableIndex) {
+	if len(chunk.Columns) > 0 {
+		idx.Columns = chunk.Columns
+	}
+	idx.Offsets = append(idx.Offsets, chunk.Offsets...)
+	idx.Clusters = append(idx.Clusters, chunk.Clusters...)
+	idx.Baskets = append(idx.Baskets, chunk.Baskets...)
+}
+
+// indexChunk is the part of the index of a table written at a checkpoint:
+// the items added since the previous checkpoint.
+// The chunks of a table are chained from the latest one, pointed at by the
+// table header, so checkpoints do not rewrite the whole index.
+type indexChunk struct {
+	Prev  int64 // position of the previous chunk, 0 for the first one
+	Index tableIndex
+}
+
+// column returns the index of the named column, or -1.
+func (idx tableIndex) column(name string) int {
+	for i, col := range idx.Columns {
+		if col == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// TableOption configures a Table at creation time.
+type TableOption func(table *Table)
+
+func NewTable(f *File, name string, opts ...TableOption) (*Table, error) {
 	var err error
 
-	table := &Table{
-		hdr: tableHeader{
+	table := &Table{}
+	err = table.init(f, name, "", opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return table, err
+}
+
+// init initializes a new table of elements of type typ and registers it
+// with file f.
+func (table *Table) init(f *File, name, typ string, opts []TableOption) error {
+	table.hdr = tableHeader{
+		tableInfo: tableInfo{
 			Name:    name,
-			Version: 0,
+			Version: tableVersion,
 			Entries: 0,
 		},
-		stream: f.f,
+		tableLayout: tableLayout{
+			Type: typ,
+		},
+	}
+	table.stream = f.f
+	table.file = f
+
+	for _, opt := range opts {
+		opt(table)
+	}
+
+	if table.hdr.Cluster > 0 && (table.hdr.Basket > 0 || table.hdr.BasketBytes > 0) {
+		return fmt.Errorf("hio: columnar table [%s] can not use baskets", name)
 	}
 
-	err = f.Set(name, table)
+	err := table.checkCodec()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return table, err
+	return f.Set(name, table)
 }
 
+// Table is a sequence of entries stored in a File.
+//
+// Any number of tables may be written to the same file, with their entries
+// interleaved. Each table retrieved with File.Get reads from its own
+// stream, through its index of entry offsets, so tables can be read
+// independently of each other and in any order.
 type Table struct {
 	hdr     tableHeader
 	stream  *rio.Stream
 	rec     *rio.Record
-	doclose bool // whether we need to close the stream ourselves
+	doclose bool  // whether we need to close the stream ourselves
+	file    *File // file being written to, if any
+	cur     int64 // index of the next entry to read
+	idx     tableIndex
+	saved   indexCount               // items of idx written to file
+	cols    *columns                 // columnar layout buffers
+	bkts    *baskets                 // baskets buffers
+	sinks   map[string]reflect.Value // scratch values for unread fields
+	sel     *Selection               // entries returned by Read
+	async   int                      // depth of the queue of asynchronous writes, 0 if synchronous
+	pipe    *pipeline                // asynchronous writes
+
+	etype    string  // name of the type of the entries written
+	fields   []Field // fields of the entries written
+	nbytes   int64   // bytes of entries written to file
+	rawbytes int64   // estimated size of entries written to file, before compression
 }
 
 func (table *Table) MarshalBinary(buf *bytes.Buffer) error {
 	enc := gob.NewEncoder(buf)
-	err := enc.Encode(&table.hdr)
-	return err
+	err := enc.Encode(&table.hdr.tableInfo)
+	if err != nil {
+		return err
+	}
+	return enc.Encode(&table.hdr.tableLayout)
 }
 
 func (table *Table) UnmarshalBinary(buf *bytes.Buffer) error {
 	dec := gob.NewDecoder(buf)
-	err := dec.Decode(&table.hdr)
-	return err
+	err := dec.Decode(&table.hdr.tableInfo)
+	if err != nil {
+		return err
+	}
+	return dec.Decode(&table.hdr.tableLayout)
 }
 
 func (table *Table) Name() string {
@@ -61,12 +222,44 @@ func (table *Table) Version() uint32 {
 	return table.hdr.Version
 }
 
+// Type returns the name of the type of the table elements, or the empty
+// string if the table was not created with a type.
+func (table *Table) Type() string {
+	return table.hdr.Type
+}
+
 func (table *Table) setStream(w *rio.Stream) {
 	table.stream = w
 }
 
+// reset drops the state of the entries being read or written, which a
+// table retrieved with File.Get would otherwise share with the table it
+// was copied from.
+func (table *Table) reset() {
+	table.rec = nil
+	table.cur = 0
+	table.cols = nil
+	table.bkts = nil
+	table.sinks = nil
+	table.sel = nil
+	table.async = 0
+	table.pipe = nil
+}
+
 func (table *Table) Close() error {
 	var err error
+	if table.file != nil && table.stream != nil {
+		err = table.flush()
+		if err != nil {
+			table.stop()
+			return err
+		}
+	}
+	err = table.stop()
+	if err != nil {
+		return err
+	}
+
 	if table.stream != nil {
 		err = table.stream.Sync()
 		if err != nil {
@@ -89,52 +282,438 @@ func (table *Table) Entries() int64 {
 }
 
 func (table *Table) Write(ptr interface{}) error {
+	if table.etype == "" {
+		if rt := reflect.TypeOf(ptr); rt != nil && rt.Kind() == reflect.Ptr {
+			table.etype = TypeName(rt.Elem())
+			table.fields = fieldsOf(rt.Elem())
+		}
+	}
+
+	if table.hdr.Cluster > 0 {
+		return table.writeColumns(ptr)
+	}
+	if table.basketed() {
+		return table.appendBasket(ptr)
+	}
+
 	if table.rec == nil {
-		rec := table.stream.Record(table.hdr.Name)
-		if rec == nil {
-			return fmt.Errorf("hio: no such table [%s]", table.hdr.Name)
+		err := table.prepare(ptr)
+		if err != nil {
+			return err
 		}
-		rec.SetCompress(true)
-		table.rec = rec
 	}
-	rec := table.rec
-	err := rec.Connect(table.hdr.Name, ptr)
-	if err != nil && err != rio.ErrBlockConnected {
+
+	var err error
+	if pipe := table.pipeline(); pipe != nil {
+		v := deepCopy(ptr)
+		err = pipe.submit(func() (func() error, error) {
+			return table.encodeEntry(v)
+		})
+	} else {
+		err = table.writeEntry(ptr)
+	}
+	if err != nil {
 		return err
 	}
-
-	err = table.stream.WriteRecord(rec)
 	table.hdr.Entries++
 
+	if table.file != nil {
+		err = table.file.written()
+	}
+
 	return err
 }
 
+// prepare prepares the record holding the entries of a row-wise table for
+// writing, given its first entry.
+func (table *Table) prepare(ptr interface{}) error {
+	table.lock()
+	defer table.unlock()
+
+	rec := table.stream.Record(table.hdr.Name)
+	if rec == nil {
+		return fmt.Errorf("hio: no such table [%s]", table.hdr.Name)
+	}
+	rec.SetCompress(table.compress())
+	table.rec = rec
+
+	if table.hdr.Entries == 0 && len(table.idx.Columns) == 0 {
+		return table.writeFields(ptr)
+	}
+	return nil
+}
+
+// writeEntry writes the entry pointed at by ptr to file and records its
+// position.
+func (table *Table) writeEntry(ptr interface{}) error {
+	write, err := table.encodeEntry(ptr)
+	if err != nil {
+		return err
+	}
+	return write()
+}
+
+// encodeEntry encodes the entry pointed at by ptr, with one block per
+// field for struct entries, and returns the function writing it to file.
+// Only the returned function accesses the stream of the file.
+func (table *Table) encodeEntry(ptr interface{}) (func() error, error) {
+	names := []string{table.hdr.Name}
+	ptrs := []interface{}{ptr}
+	if len(table.idx.Columns) > 0 {
+		rv, err := structOf(ptr)
+		if err != nil {
+			return nil, err
+		}
+		names = table.idx.Columns
+		ptrs = make([]interface{}, len(names))
+		for i, name := range names {
+			fv := rv.FieldByName(name)
+			if !fv.IsValid() {
+				return nil, fmt.Errorf("hio: no field [%s] in %T", name, ptr)
+			}
+			ptrs[i] = fv.Addr().Interface()
+		}
+	}
+
+	blks := make([]interface{}, len(ptrs))
+	for i, ptr := range ptrs {
+		blk, err := table.encode(ptr)
+		if err != nil {
+			return nil, err
+		}
+		blks[i] = blk
+	}
+	rawsize := sizeOf(reflect.ValueOf(ptr))
+
+	return func() error {
+		table.lock()
+		defer table.unlock()
+
+		for i, blk := range blks {
+			err := table.rec.Connect(names[i], blk)
+			if err != nil && err != rio.ErrBlockConnected {
+				return err
+			}
+		}
+
+		pos := table.stream.CurPos()
+		err := table.stream.WriteRecord(table.rec)
+		if err != nil {
+			return err
+		}
+		table.idx.Offsets = append(table.idx.Offsets, pos)
+		table.nbytes += table.stream.CurPos() - pos
+		table.rawbytes += rawsize
+		return nil
+	}, nil
+}
+
+// Read reads the next selected entry of the table into ptr.
 func (table *Table) Read(ptr interface{}) error {
+	for {
+		table.skip()
+		err := table.read(ptr)
+		if err != nil {
+			return err
+		}
+		ok, err := table.match(ptr)
+		if err != nil || ok {
+			return err
+		}
+	}
+}
+
+// read reads the next entry of the table into ptr.
+func (table *Table) read(ptr interface{}) error {
+	// entries past the header count were written after the last checkpoint
+	if table.cur >= table.hdr.Entries {
+		return io.EOF
+	}
+
+	if table.indexed(table.cur) {
+		return table.ReadAt(table.cur, ptr)
+	}
+
+	// no index (file written by an older version): scan the stream.
+	rec, err := table.record(ptr, nil)
+	if err != nil {
+		return err
+	}
+
+	for {
+		rec, err = table.stream.ReadRecord()
+		if err != nil {
+			return err
+		}
+		if rec.Name() == table.hdr.Name {
+			break
+		}
+	}
+	table.cur++
+	return err
+}
+
+// ReadAt reads the i-th entry of the table into ptr.
+// The next call to Read will read the entry following it.
+func (table *Table) ReadAt(i int64, ptr interface{}) error {
+	if i < 0 {
+		return fmt.Errorf("hio: negative entry index [%d]", i)
+	}
+	if i >= table.hdr.Entries {
+		return io.EOF
+	}
+	if !table.indexed(i) {
+		return fmt.Errorf("hio: table [%s] has no index for entry [%d]", table.hdr.Name, i)
+	}
+
+	var err error
+	switch {
+	case table.hdr.Cluster > 0:
+		err = table.readColumns(i, ptr, nil)
+	case table.basketed():
+		err = table.readBasket(i, ptr)
+	default:
+		err = table.readRow(i, ptr, nil)
+	}
+	if err != nil {
+		return err
+	}
+	table.cur = i + 1
+	return err
+}
+
+// readRow reads the named fields of entry i of a row-wise table into ptr.
+func (table *Table) readRow(i int64, ptr interface{}, names []string) error {
+	rec, err := table.record(ptr, names)
+	if err != nil {
+		return err
+	}
+
+	_, err = table.stream.Seek(table.idx.Offsets[i], 0)
+	if err != nil {
+		return err
+	}
+
+	rec, err = table.stream.ReadRecord()
+	if err != nil {
+		return err
+	}
+	if rec.Name() != table.hdr.Name {
+		return fmt.Errorf(
+			"hio: invalid record [%s] for entry [%d] of table [%s]",
+			rec.Name(), i, table.hdr.Name,
+		)
+	}
+
+	return err
+}
+
+// Seek sets the index of the next entry to be read by Read to offset,
+// interpreted according to whence: io.SeekStart means relative to the first
+// entry, io.SeekCurrent relative to the current entry and io.SeekEnd
+// relative to the end of the table.
+// Seek returns the new entry index.
+func (table *Table) Seek(offset int64, whence int) (int64, error) {
+	var i int64
+	switch whence {
+	case io.SeekStart:
+		i = offset
+	case io.SeekCurrent:
+		i = table.cur + offset
+	case io.SeekEnd:
+		i = table.hdr.Entries + offset
+	default:
+		return table.cur, fmt.Errorf("hio: invalid whence [%d]", whence)
+	}
+
+	if i < 0 || i > table.hdr.Entries {
+		return table.cur, fmt.Errorf("hio: entry index [%d] out of range [0, %d]", i, table.hdr.Entries)
+	}
 
+	if i == 0 && !table.indexed(0) {
+		// no index: restart scanning from the beginning of the stream.
+		_, err := table.stream.Seek(0, 0)
+		if err != nil {
+			return table.cur, err
+		}
+	} else if i < table.hdr.Entries && !table.indexed(i) {
+		return table.cur, fmt.Errorf("hio: table [%s] has no index for entry [%d]", table.hdr.Name, i)
+	}
+
+	table.cur = i
+	return i, nil
+}
+
+// Rewind resets the table so the next call to Read reads its first entry.
+func (table *Table) Rewind() error {
+	_, err := table.Seek(0, io.SeekStart)
+	return err
+}
+
+// indexed returns whether the position on file of entry i is known.
+func (table *Table) indexed(i int64) bool {
+	if table.hdr.Cluster > 0 || table.basketed() {
+		return true
+	}
+	return i < int64(len(table.idx.Offsets))
+}
+
+// flush writes pending entries to file.
+func (table *Table) flush() error {
+	var err error
+	switch {
+	case table.hdr.Cluster > 0:
+		err = table.flushColumns()
+	case table.basketed():
+		err = table.flushBasket()
+	}
+	if err != nil {
+		return err
+	}
+	if table.pipe != nil {
+		return table.pipe.wait()
+	}
+	return nil
+}
+
+// record returns the record holding the table entries, connected to ptr for
+// reading the named fields.
+func (table *Table) record(ptr interface{}, names []string) (*rio.Record, error) {
 	if table.rec == nil {
 		rec := table.stream.Record(table.hdr.Name)
 		if rec == nil {
-			return fmt.Errorf("hio: no such table [%s]", table.hdr.Name)
+			return nil, fmt.Errorf("hio: no such table [%s]", table.hdr.Name)
 		}
 		rec.SetUnpack(true)
 		table.rec = rec
 	}
 	rec := table.rec
 
-	err := rec.Connect(table.hdr.Name, ptr)
-	if err != nil && err != rio.ErrBlockConnected {
+	err := table.connect(rec, ptr, names)
+	if err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// connect connects the blocks of the entry record rec to ptr.
+//
+// Entries of struct type are stored with one block per exported field.
+// Only the blocks of the named fields (or, if none is named, of all the
+// fields of the struct pointed at by ptr) are connected to ptr: rio skips
+// the decoding of the other blocks.
+// Blocks connected by a previous call are redirected to scratch values.
+func (table *Table) connect(rec *rio.Record, ptr interface{}, names []string) error {
+	if len(table.idx.Columns) == 0 {
+		if len(names) > 0 {
+			return fmt.Errorf("hio: entries of table [%s] have no fields", table.hdr.Name)
+		}
+		blk, err := table.wrap(ptr)
+		if err != nil {
+			return err
+		}
+		err = rec.Connect(table.hdr.Name, blk)
+		if err != nil && err != rio.ErrBlockConnected {
+			return err
+		}
+		return nil
+	}
+
+	rv, err := structOf(ptr)
+	if err != nil {
 		return err
 	}
 
-	for {
-		rec, err = table.stream.ReadRecord()
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		if table.idx.column(name) < 0 {
+			return fmt.Errorf("hio: no column [%s] in table [%s]", name, table.hdr.Name)
+		}
+		if !rv.FieldByName(name).IsValid() {
+			return fmt.Errorf("hio: no field [%s] in %T", name, ptr)
+		}
+		want[name] = true
+	}
+
+	if table.sinks == nil {
+		table.sinks = make(map[string]reflect.Value)
+	}
+
+	for _, name := range table.idx.Columns {
+		var dst interface{}
+		fv := rv.FieldByName(name)
+		if fv.IsValid() && (len(names) == 0 || want[name]) {
+			dst = fv.Addr().Interface()
+			if _, ok := table.sinks[name]; !ok {
+				table.sinks[name] = reflect.New(fv.Type())
+			}
+		} else {
+			sink, ok := table.sinks[name]
+			if !ok {
+				// never connected.
+				continue
+			}
+			dst = sink.Interface()
+		}
+
+		blk, err := table.wrap(dst)
 		if err != nil {
 			return err
 		}
-		if rec.Name() == table.hdr.Name {
-			break
+		err = rec.Connect(name, blk)
+		if err != nil && err != rio.ErrBlockConnected {
+			return err
 		}
 	}
+
+	return nil
+}
+
+// writeFields records the names of the fields of the struct pointed at by
+// ptr as the columns of a row-wise table, and writes them to file.
+// Nothing is done if entries are not structs with only exported fields.
+func (table *Table) writeFields(ptr interface{}) error {
+	rt := reflect.TypeOf(ptr)
+	if rt.Kind() != reflect.Ptr || rt.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	if _, ok := rt.MethodByName("MarshalBinary"); ok {
+		// custom encoding.
+		return nil
+	}
+
+	rt = rt.Elem()
+	names := make([]string, 0, rt.NumField())
+	for i := 0; i < rt.NumField(); i++ {
+		ft := rt.Field(i)
+		if ft.PkgPath != "" || ft.Anonymous {
+			return nil
+		}
+		names = append(names, ft.Name)
+	}
+
+	return table.storeFields(names)
+}
+
+// storeFields records names as the columns of a row-wise table, and writes
+// them to file.
+func (table *Table) storeFields(names []string) error {
+	recname := "hio.Fields/" + table.hdr.Name
+	rec := table.stream.Record(recname)
+	err := rec.Connect("hio.Fields", &names)
+	if err != nil && err != rio.ErrBlockConnected {
+		return err
+	}
+
+	pos := table.stream.CurPos()
+	err = table.stream.WriteRecord(rec)
+	if err != nil {
+		return err
+	}
+	table.nbytes += table.stream.CurPos() - pos
+
+	table.idx.Columns = names
 	return err
 }
 
@@ -152,14 +152,428 @@ func testTableRead(t *testing.T, fname string) {
 
 }
 
+func TestTableReadAt(t *testing.T) {
+	const fname = "testdata/table-readat.hio"
+	const nentries = 10
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer table.Close()
+
+	for _, i := range rand.Perm(nentries) {
+		var data tableData
+		err = table.ReadAt(int64(i), &data)
+		if err != nil {
+			t.Fatalf("could not read table [name=%s, i=%d]: %v", fname, i, err)
+		}
+		if !reflect.DeepEqual(data, newTableData(i)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, newTableData(i), data)
+		}
+	}
+
+	for _, test := range []struct {
+		offset int64
+		whence int
+		want   int
+	}{
+		{offset: 5, whence: io.SeekStart, want: 5},
+		{offset: -2, whence: io.SeekCurrent, want: 4},
+		{offset: -1, whence: io.SeekEnd, want: nentries - 1},
+		{offset: 0, whence: io.SeekStart, want: 0},
+	} {
+		i, err := table.Seek(test.offset, test.whence)
+		if err != nil {
+			t.Fatalf("could not seek to (%d, %d): %v", test.offset, test.whence, err)
+		}
+		if i != int64(test.want) {
+			t.Fatalf("seek to (%d, %d): expected entry %d. got %d", test.offset, test.whence, test.want, i)
+		}
+
+		var data tableData
+		err = table.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read table [name=%s, i=%d]: %v", fname, test.want, err)
+		}
+		if !reflect.DeepEqual(data, newTableData(test.want)) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", test.want, newTableData(test.want), data)
+		}
+	}
+
+	err = table.Rewind()
+	if err != nil {
+		t.Fatalf("could not rewind table: %v", err)
+	}
+	for i := 0; i < nentries; i++ {
+		var data tableData
+		err = table.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read table [name=%s, i=%d]: %v", fname, i, err)
+		}
+	}
+
+	var data tableData
+	err = table.ReadAt(nentries, &data)
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF reading past the end of table. got %v", err)
+	}
+
+	_, err = table.Seek(nentries+1, io.SeekStart)
+	if err == nil {
+		t.Fatalf("expected an error seeking past the end of table")
+	}
+}
+
+func newTableData(i int) tableData {
+	return tableData{
+		Ints: []int64{
+			int64(i) + 100,
+			int64(i) + 200,
+			int64(i) + 300,
+		},
+		Floats: []float64{
+			float64(i) + 100,
+			float64(i) + 200,
+			float64(i) + 300,
+		},
+		Strings: []string{
+			fmt.Sprintf("my-string-%d", i+100),
+			fmt.Sprintf("my-string-%d", i+200),
+			fmt.Sprintf("my-string-%d", i+300),
+		},
+	}
+}
+
+func TestTableGetTwice(t *testing.T) {
+	const fname = "testdata/table-get-twice.hio"
+	const nentries = 10
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var t1 Table
+	err = f.Get(tname, &t1)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer t1.Close()
+
+	t1.Filter(func(ptr interface{}) bool {
+		return ptr.(*tableData).Ints[0]%2 == 0
+	})
+	var data tableData
+	err = t1.Read(&data)
+	if err != nil {
+		t.Fatalf("could not read table [name=%s]: %v", tname, err)
+	}
+
+	// the second table reads all the entries, from the first one.
+	var t2 Table
+	err = f.Get(tname, &t2)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer t2.Close()
+
+	for i := 0; i < nentries; i++ {
+		var data tableData
+		err = t2.Read(&data)
+		if err != nil {
+			t.Fatalf("could not read table [name=%s, i=%d]: %v", tname, i, err)
+		}
+		if want := newTableData(i); !reflect.DeepEqual(data, want) {
+			t.Fatalf("entry %d: expected %v. got %v", i, want, data)
+		}
+	}
+
+	// the first table keeps its selection and position.
+	n := 1
+	for {
+		var data tableData
+		err = t1.Read(&data)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not read table [name=%s]: %v", tname, err)
+		}
+		n++
+	}
+	if n != nentries/2 {
+		t.Fatalf("expected %d selected entries. got %d", nentries/2, n)
+	}
+}
+
+func TestTableProjection(t *testing.T) {
+	const fname = "testdata/table-projection.hio"
+	const nentries = 10
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer table.Close()
+
+	// a struct holding a subset of the fields of the entries.
+	type floatsData struct {
+		Floats []float64
+	}
+
+	for i := 0; i < nentries; i++ {
+		want := newTableData(i)
+		switch i % 3 {
+		case 0:
+			var data floatsData
+			err = table.ReadColumns(&data)
+			if err != nil {
+				t.Fatalf("could not read table [name=%s, i=%d]: %v", tname, i, err)
+			}
+			if !reflect.DeepEqual(data.Floats, want.Floats) {
+				t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, want.Floats, data.Floats)
+			}
+		case 1:
+			var data tableData
+			err = table.ReadColumns(&data, "Strings")
+			if err != nil {
+				t.Fatalf("could not read table [name=%s, i=%d]: %v", tname, i, err)
+			}
+			want.Ints = nil
+			want.Floats = nil
+			if !reflect.DeepEqual(data, want) {
+				t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, want, data)
+			}
+		case 2:
+			var data tableData
+			err = table.Read(&data)
+			if err != nil {
+				t.Fatalf("could not read table [name=%s, i=%d]: %v", tname, i, err)
+			}
+			if !reflect.DeepEqual(data, want) {
+				t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, want, data)
+			}
+		}
+	}
+
+	err = table.Rewind()
+	if err != nil {
+		t.Fatalf("could not rewind table: %v", err)
+	}
+
+	var data tableData
+	err = table.ReadColumns(&data, "Bools")
+	if err == nil {
+		t.Fatalf("expected an error reading a non-existing column")
+	}
+
+	var floats floatsData
+	err = table.ReadColumns(&floats, "Ints")
+	if err == nil {
+		t.Fatalf("expected an error reading a column into a struct without that field")
+	}
+}
+
+func TestTableUpdate(t *testing.T) {
+	const fname = "testdata/table-update.hio"
+	const nentries = 10
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+	testTableCreate(t, fname)
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s] for update: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		var table Table
+		err = f.Get(tname, &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+		}
+
+		for i := nentries; i < 2*nentries; i++ {
+			data := tableData{
+				Ints:    []int64{int64(i) + 100},
+				Floats:  []float64{float64(i) + 100},
+				Strings: []string{fmt.Sprintf("my-string-%d", i+100)},
+			}
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write to table [name=%s, i=%d]: %v", fname, i, err)
+			}
+		}
+
+		if table.Entries() != 2*nentries {
+			t.Fatalf("expected [%d] entries. got [%d]", 2*nentries, table.Entries())
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer table.Close()
+
+	if table.Entries() != 2*nentries {
+		t.Fatalf("expected [%d] entries. got [%d]", 2*nentries, table.Entries())
+	}
+
+	for i := 0; i < 2*nentries+1; i++ {
+		var data tableData
+		err = table.Read(&data)
+		if i == 2*nentries {
+			if err != io.EOF {
+				t.Fatalf("read too many entries (err=%#v)", err)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not read table [name=%s, i=%d]: %v", fname, i, err)
+		}
+		if data.Ints[0] != int64(i)+100 {
+			t.Fatalf("expected (n=%d): %d. got %d", i, int64(i)+100, data.Ints[0])
+		}
+	}
+}
+
+func TestTableUpdateNoIndex(t *testing.T) {
+	// testdata/read-table.hio was written before tables had an index.
+	const fname = "testdata/table-update-noindex.hio"
+	const nentries = 10
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+
+	buf, err := os.ReadFile("testdata/read-table.hio")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(fname, buf, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	func() {
+		f, err := OpenFile(fname, os.O_RDWR)
+		if err != nil {
+			t.Fatalf("could not open file [%s] for update: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		var table Table
+		err = f.Get(tname, &table)
+		if err != nil {
+			t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+		}
+
+		for i := nentries; i < 2*nentries; i++ {
+			data := newTableData(i)
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write to table [name=%s, i=%d]: %v", fname, i, err)
+			}
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var table Table
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+	}
+	defer table.Close()
+
+	if f.Version() != g_version {
+		t.Fatalf("expected version %d. got %d", g_version, f.Version())
+	}
+
+	if table.Entries() != 2*nentries {
+		t.Fatalf("expected [%d] entries. got [%d]", 2*nentries, table.Entries())
+	}
+
+	for i := 0; i < 2*nentries+1; i++ {
+		var data tableData
+		err = table.Read(&data)
+		if i == 2*nentries {
+			if err != io.EOF {
+				t.Fatalf("read too many entries (err=%#v)", err)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("could not read table [name=%s, i=%d]: %v", fname, i, err)
+		}
+		if want := newTableData(i); !reflect.DeepEqual(data, want) {
+			t.Fatalf("entry %d: expected %v. got %v", i, want, data)
+		}
+	}
+
+	for _, i := range []int64{2*nentries - 1, 0, nentries, nentries - 1} {
+		var data tableData
+		err = table.ReadAt(i, &data)
+		if err != nil {
+			t.Fatalf("could not read entry [%d] of table [name=%s]: %v", i, fname, err)
+		}
+		if want := newTableData(int(i)); !reflect.DeepEqual(data, want) {
+			t.Fatalf("entry %d: expected %v. got %v", i, want, data)
+		}
+	}
+}
+
 func TestTableHist(t *testing.T) {
 	const fname = "testdata/table-hist.hio"
 	const nentries = 10
 	const tname = "my-table"
-	//os.RemoveAll(fname)
-
-	// FIXME(sbinet)
-	t.Skip("not ready for prime-time")
+	defer os.RemoveAll(fname)
 
 	href := func() *hbook.H1D {
 		f, err := Create(fname)
@@ -300,14 +714,16 @@ func TestTableHist(t *testing.T) {
 }
 
 func Test2Tables(t *testing.T) {
-	const fname = "testdata/table.hio"
+	t.Run("sequential", test2TablesSequential)
+	t.Run("interleaved", test2TablesInterleaved)
+}
+
+func test2TablesSequential(t *testing.T) {
+	const fname = "testdata/table-2-sequential.hio"
 	const nentries = 10
 	const tname1 = "my-table-1"
 	const tname2 = "my-table-2"
-	//os.RemoveAll(fname)
-
-	// FIXME(sbinet)
-	t.Skip("not ready for prime-time")
+	defer os.RemoveAll(fname)
 
 	func() {
 		f, err := Create(fname)
@@ -426,6 +842,116 @@ func Test2Tables(t *testing.T) {
 
 }
 
+func test2TablesInterleaved(t *testing.T) {
+	const fname = "testdata/table-2-interleaved.hio"
+	const nentries = 10
+	const tname1 = "my-table-1"
+	const tname2 = "my-table-2"
+	defer os.RemoveAll(fname)
+
+	tnames := []string{tname1, tname2}
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		if f.Name() != fname {
+			t.Fatalf("expected name %q. got %q", fname, f.Name())
+		}
+
+		tables := make([]*Table, len(tnames))
+		for j, tname := range tnames {
+			table, err := NewTable(f, tname)
+			if err != nil {
+				t.Fatalf("could not create table [%s]: %v", fname, err)
+			}
+
+			if table.Name() != tname {
+				t.Fatalf("expected table name [%s]. got [%s]", tname, table.Name())
+			}
+			tables[j] = table
+		}
+
+		// interleave entries of both tables.
+		for i := 0; i < nentries; i++ {
+			for j, table := range tables {
+				data := newTableData(i + 1000*j)
+				err = table.Write(&data)
+				if err != nil {
+					t.Fatalf("could not write to table [name=%s, i=%d]: %v", table.Name(), i, err)
+				}
+			}
+		}
+
+		for _, table := range tables {
+			if table.Entries() != nentries {
+				t.Fatalf("expected [%d] entries. got [%d]", nentries, table.Entries())
+			}
+		}
+	}()
+
+	func() {
+
+		f, err := Open(fname)
+		if err != nil {
+			t.Fatalf("could not open file [%s]: %v", fname, err)
+		}
+		defer f.Close()
+
+		// retrieve tables in reverse order.
+		tables := make([]*Table, len(tnames))
+		for j := len(tnames) - 1; j >= 0; j-- {
+			tname := tnames[j]
+			var table Table
+			err = f.Get(tname, &table)
+			if err != nil {
+				t.Fatalf("could not retrieve table [name=%s, file=%s]: %v", tname, fname, err)
+			}
+			defer table.Close()
+
+			if table.Name() != tname {
+				t.Fatalf("expected table name [%s]. got [%s]", tname, table.Name())
+			}
+
+			if table.Entries() != nentries {
+				t.Fatalf("expected [%d] entries. got [%d]", nentries, table.Entries())
+			}
+			tables[j] = &table
+		}
+
+		// read both tables alternately.
+		for i := 0; i < nentries+1; i++ {
+			for j, table := range tables {
+				var data tableData
+				err = table.Read(&data)
+				if i == nentries {
+					if err != io.EOF {
+						t.Fatalf("read too many entries (err=%#v)", err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("could not read table [name=%s, i=%d]: %v", table.Name(), i, err)
+				}
+
+				refdata := newTableData(i + 1000*j)
+				if !reflect.DeepEqual(data, refdata) {
+					t.Fatalf("expected (table=%s, n=%d):\nref=%v\nnew=%v", table.Name(), i, refdata, data)
+				}
+			}
+		}
+	}()
+
+}
+
 func TestHist(t *testing.T) {
 	const fname = "testdata/hist.hio"
 	const nentries = 10
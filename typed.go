@@ -0,0 +1,120 @@
+package hio
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"reflect"
+)
+
+// TypedTable is a Table holding elements of type T.
+//
+// The name of T is recorded in the table header when the table is created
+// and checked when the table is retrieved with File.Get, so a table can not
+// be read back into a type different from the one it was written with.
+// Tables created with NewTable are checked against the type of the entries
+// written to them.
+type TypedTable[T any] struct {
+	Table
+	err error
+}
+
+// NewTypedTable creates a new table of elements of type T in file f.
+func NewTypedTable[T any](f *File, name string, opts ...TableOption) (*TypedTable[T], error) {
+	var err error
+
+	tt := &TypedTable[T]{}
+	err = tt.Table.init(f, name, tt.typename(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return tt, err
+}
+
+// Write writes v as a new entry of the table.
+func (tt *TypedTable[T]) Write(v *T) error {
+	return tt.Table.Write(v)
+}
+
+// Read reads the next entry of the table into v.
+func (tt *TypedTable[T]) Read(v *T) error {
+	return tt.Table.Read(v)
+}
+
+// All returns an iterator over the remaining entries of the table and
+// their index.
+// Iteration stops at the end of the table or at the first error, which is
+// then reported by Err.
+func (tt *TypedTable[T]) All() iter.Seq2[int64, T] {
+	return func(yield func(int64, T) bool) {
+		for {
+			var v T
+			err := tt.Read(&v)
+			if err != nil {
+				if err != io.EOF {
+					tt.err = err
+				}
+				return
+			}
+			if !yield(tt.cur-1, v) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the first error encountered while iterating with All.
+func (tt *TypedTable[T]) Err() error {
+	return tt.err
+}
+
+func (tt *TypedTable[T]) table() *Table {
+	return &tt.Table
+}
+
+func (tt *TypedTable[T]) typename() string {
+	return TypeName(reflect.TypeOf((*T)(nil)).Elem())
+}
+
+// typedTable is implemented by all TypedTable[T] types.
+type typedTable interface {
+	table() *Table
+	typename() string
+}
+
+// getTyped retrieves the named table and checks its elements have the
+// type expected by tt.
+func (f *File) getTyped(name string, tt typedTable) error {
+	table := tt.table()
+	err := f.Get(name, table)
+	if err != nil {
+		return err
+	}
+
+	typ := table.Type()
+	if typ == "" {
+		// plain table: type of the entries written to it, if any.
+		typ = f.meta[name].Type
+	}
+	if typ != "" && typ != tt.typename() {
+		table.Close()
+		return fmt.Errorf(
+			"hio: table [%s] holds elements of type [%s], not [%s]",
+			name, typ, tt.typename(),
+		)
+	}
+
+	return nil
+}
+
+// TypeName returns the fully qualified name of type rt, as recorded in
+// KeyInfo.Type for values and entries of that type.
+func TypeName(rt reflect.Type) string {
+	if rt.Name() == "" || rt.PkgPath() == "" {
+		return rt.String()
+	}
+	return rt.PkgPath() + "." + rt.Name()
+}
+
+// EOF
@@ -0,0 +1,154 @@
+package hio
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestTypedTable(t *testing.T) {
+	const fname = "testdata/typed-table.hio"
+	const tname = "my-table"
+	const nentries = 10
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		table, err := NewTypedTable[MyStruct](f, tname)
+		if err != nil {
+			t.Fatalf("could not create table [%s]: %v", tname, err)
+		}
+
+		for i := 0; i < nentries; i++ {
+			data := newMyStruct(int64(i))
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write to table [i=%d]: %v", i, err)
+			}
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var bad TypedTable[tableData]
+	err = f.Get(tname, &bad)
+	if err == nil {
+		t.Fatalf("expected an error retrieving a table with the wrong type")
+	}
+
+	var table TypedTable[MyStruct]
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	if table.Type() != "github.com/go-hep/hio.MyStruct" {
+		t.Fatalf("invalid table type: %q", table.Type())
+	}
+
+	n := int64(0)
+	for i, v := range table.All() {
+		if i != n {
+			t.Fatalf("expected index %d. got %d", n, i)
+		}
+		want := newMyStruct(i)
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("expected (n=%d):\nref=%v\nnew=%v", i, want, v)
+		}
+		n++
+	}
+	if err := table.Err(); err != nil {
+		t.Fatalf("could not iterate over table: %v", err)
+	}
+	if n != nentries {
+		t.Fatalf("expected [%d] entries. got [%d]", nentries, n)
+	}
+}
+
+func TestTypedTableFromPlainTable(t *testing.T) {
+	const fname = "testdata/typed-table-plain.hio"
+	const tname = "my-table"
+	defer os.RemoveAll(fname)
+
+	func() {
+		f, err := Create(fname)
+		if err != nil {
+			t.Fatalf("could not create file [%s]: %v", fname, err)
+		}
+		defer func() {
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("could not close file [%s]: %v", fname, err)
+			}
+		}()
+
+		table, err := NewTable(f, tname)
+		if err != nil {
+			t.Fatalf("could not create table [%s]: %v", tname, err)
+		}
+
+		for i := 0; i < 10; i++ {
+			data := newMyStruct(int64(i))
+			err = table.Write(&data)
+			if err != nil {
+				t.Fatalf("could not write to table [i=%d]: %v", i, err)
+			}
+		}
+	}()
+
+	f, err := Open(fname)
+	if err != nil {
+		t.Fatalf("could not open file [%s]: %v", fname, err)
+	}
+	defer f.Close()
+
+	var bad TypedTable[tableData]
+	err = f.Get(tname, &bad)
+	if err == nil {
+		t.Fatalf("expected an error retrieving a table with the wrong type")
+	}
+
+	var table TypedTable[MyStruct]
+	err = f.Get(tname, &table)
+	if err != nil {
+		t.Fatalf("could not retrieve table [%s]: %v", tname, err)
+	}
+	defer table.Close()
+
+	var v MyStruct
+	err = table.Read(&v)
+	if err != nil {
+		t.Fatalf("could not read entry: %v", err)
+	}
+	if want := newMyStruct(0); !reflect.DeepEqual(v, want) {
+		t.Fatalf("expected:\nref=%v\nnew=%v", want, v)
+	}
+}
+
+func newMyStruct(i int64) MyStruct {
+	return MyStruct{
+		Float:   float64(i),
+		Int:     i,
+		String:  "mystruct",
+		Floats:  []float64{float64(i)},
+		Ints:    []int64{i},
+		Strings: []string{"str"},
+	}
+}
+
+// EOF
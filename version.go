@@ -2,6 +2,11 @@ package hio
 
 type Version uint32
 
-var g_version = Version(0)
+// g_version is the version of the files written by this package.
+//
+// Version 1 stores struct values field by field, the description of keys
+// and the directories in the footer, and the layout and entries index in
+// the header of tables.
+var g_version = Version(1)
 
 // EOF